@@ -201,3 +201,116 @@ func TestAdapter_ContextAffectsDependencyReuse(t *testing.T) {
 		t.Fatalf("expected child-adp NOT to be reused (different parent contexts), but got same instance: %p", c1)
 	}
 }
+
+func TestRegistry_Explain(t *testing.T) {
+	if _, err := core.SetDefaultSearchPath("testdata"); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	core.Register("lister-adp", func() core.Adapter { return &ListerAdp{} })
+	core.Register("child-adp", func() core.Adapter { return &ChildAdp{} })
+	core.Register("adp", func() core.Adapter { return &Adp{} })
+
+	e, err := core.Explain("adp", "items/inst1")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+
+	sources := map[string]core.ValueSource{}
+	for _, v := range e.Values {
+		sources[v.Pointer] = v.Source
+	}
+
+	inst1 := filepath.Join("testdata", "items", "inst1.json")
+	adpFile := filepath.Join("testdata", "adp.json")
+	want := map[string]string{
+		"/spec/foo":                    inst1,
+		"/spec/label":                  inst1,
+		"/work_dir":                    inst1,
+		"/dependencies/ListerProvider": adpFile,
+	}
+	for ptr, file := range want {
+		src, ok := sources[ptr]
+		if !ok {
+			t.Fatalf("missing explained value %s in %+v", ptr, e.Values)
+		}
+		abs, _ := filepath.Abs(file)
+		if src.Kind != core.SourceFile || src.Path != abs || src.Pointer != ptr {
+			t.Fatalf("source of %s = %+v, want file %s", ptr, src, abs)
+		}
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	core.Register("lister-adp", func() core.Adapter { return &ListerAdp{} })
+	core.Register("child-adp", func() core.Adapter { return &ChildAdp{} })
+	core.Register("adp", func() core.Adapter { return &Adp{} })
+
+	if _, err := core.SetDefaultSearchPath("testdata"); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	problems, err := core.Validate()
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("Validate(testdata) reported problems: %v", problems)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "adp.json"),
+		`{"adapter":"adp","dependencies":{"ListerProvider":{"adapter":"adp"},"Missing":{"adapter":"nope"}}}`)
+	writeFile(t, filepath.Join(dir, "ghost.json"), `{"adapter":"ghost"}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	problems, err = core.Validate()
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	var msgs []string
+	for _, p := range problems {
+		msgs = append(msgs, p.Message)
+	}
+	want := []string{
+		`dependency "ListerProvider": adapter "adp" (*core_test.Adp) not assignable to field ListerProvider (core.Lister)`,
+		`dependency "Missing": adapter "nope" is not registered`,
+		`required dependency "ChildProvider" is not configured`,
+		`adapter is not registered`,
+	}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Fatalf("problems = %q, want %q", msgs, want)
+	}
+}
+
+// TagDefaultAdp defaults its Lister in a tag; configs may override it.
+type TagDefaultAdp struct {
+	Spec   struct{}
+	Lister core.Lister `core:"tag-default-lister"`
+}
+
+func (a *TagDefaultAdp) ConfigPtr() any                { return &a.Spec }
+func (a *TagDefaultAdp) ItemConfigPtr(name string) any { return &a.Spec }
+
+func TestAdapter_ConfigDependencyOverridesTagDefault(t *testing.T) {
+	core.Register("tag-default-adp", func() core.Adapter { return &TagDefaultAdp{} })
+	core.Register("tag-default-lister", func() core.Adapter { return &ChildAdp{} })
+	core.Register("cfg-lister", func() core.Adapter { return &ListerAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tag-default-adp.json"), `{"dependencies":{"lister":{"adapter":"cfg-lister"}}}`)
+	writeFile(t, filepath.Join(dir, "items", "tag-default-item.json"), `{"adapter":"tag-default-adp"}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	for _, args := range [][]string{nil, {"tag-default-item"}} {
+		a, err := core.NewAdapterAs[*TagDefaultAdp]("tag-default-adp", args...)
+		if err != nil {
+			t.Fatalf("NewAdapterAs(%v): %v", args, err)
+		}
+		if _, ok := a.Lister.(*ListerAdp); !ok {
+			t.Fatalf("NewAdapterAs(%v).Lister = %T, want the configured *ListerAdp", args, a.Lister)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -10,37 +11,49 @@ import (
 	"github.com/bartdeboer/words"
 )
 
+// depFactory constructs (or reuses) a dependency adapter on behalf of its parent.
+type depFactory func(adapterID string, parentWorkDir string, args ...string) (Adapter, error)
+
 // applyDeps wires dependencies into an adapter using both map-style (Depender) and
-// struct-field injection.
-func applyDeps(adapter Adapter, parentWorkDir string, meta *MetaHeader) error {
+// struct-field injection. The dependencies of later metas (the item config)
+// override those of earlier ones (the adapter config), which override the
+// `core` tag defaults. toHost translates the absolute work dirs set in
+// configs; relative ones join parentWorkDir, which is already a host path.
+func applyDeps(adapter Adapter, parentWorkDir string, toHost func(string) string, newDep depFactory, metas ...*MetaHeader) error {
 	// infer from struct tags regardless of meta
 	inferred, err := findStructDeps(adapter)
 	if err != nil {
 		return err
 	}
 
-	var cfg map[string]DepRef
-	if meta != nil {
-		cfg = meta.Dependencies
+	deps := inferred
+	for _, meta := range metas {
+		if meta != nil {
+			deps = mergeDeps(meta.Dependencies, deps)
+		}
 	}
-
-	deps := mergeDeps(cfg, inferred)
-	if deps == nil {
+	if len(deps) == 0 {
 		return nil
 	}
+	for name, ref := range deps {
+		if toHost != nil && filepath.IsAbs(ref.WorkDir) {
+			ref.WorkDir = toHost(ref.WorkDir)
+			deps[name] = ref
+		}
+	}
 
 	if depender, ok := adapter.(Depender); ok {
-		if err := resolveMapDeps(depender, parentWorkDir, deps); err != nil {
+		if err := resolveMapDeps(depender, parentWorkDir, deps, newDep); err != nil {
 			return err
 		}
 	}
-	if err := resolveStructDeps(adapter, parentWorkDir, deps); err != nil {
+	if err := resolveStructDeps(adapter, parentWorkDir, deps, newDep); err != nil {
 		return err
 	}
 	return nil
 }
 
-func resolveMapDeps(target Depender, parentWorkDir string, deps map[string]DepRef) error {
+func resolveMapDeps(target Depender, parentWorkDir string, deps map[string]DepRef, newDep depFactory) error {
 	for name, ref := range deps {
 		var alias string
 		switch {
@@ -66,7 +79,7 @@ func resolveMapDeps(target Depender, parentWorkDir string, deps map[string]DepRe
 		}
 		depArgs = append(depArgs, ref.Args...)
 
-		depAdapter, err := newAdapterWithContext(ref.Adapter, parentWorkDir, depArgs...)
+		depAdapter, err := newDep(ref.Adapter, ref.workDir(parentWorkDir), depArgs...)
 		if err != nil {
 			return fmt.Errorf("failed loading dependency %q: %w", name, err)
 		}
@@ -77,7 +90,7 @@ func resolveMapDeps(target Depender, parentWorkDir string, deps map[string]DepRe
 
 // resolveStructDeps initialises and assigns dependencies to exported
 // pointer fields on the parent whose names match deps' keys.
-func resolveStructDeps(target any, parentWorkDir string, deps map[string]DepRef) error {
+func resolveStructDeps(target any, parentWorkDir string, deps map[string]DepRef, newDep depFactory) error {
 	v := reflect.ValueOf(target)
 	if v.Kind() != reflect.Ptr {
 		return fmt.Errorf("resolveStructDeps: target must be a pointer, got %T", target)
@@ -104,7 +117,7 @@ func resolveStructDeps(target any, parentWorkDir string, deps map[string]DepRef)
 		}
 
 		// Pass the parent context path
-		dep, err := newAdapterWithContext(ref.Adapter, parentWorkDir, childArgs...)
+		dep, err := newDep(ref.Adapter, ref.workDir(parentWorkDir), childArgs...)
 		if err != nil {
 			return fmt.Errorf("dependency %q: %w", fieldName, err)
 		}
@@ -124,6 +137,18 @@ func resolveStructDeps(target any, parentWorkDir string, deps map[string]DepRef)
 	return nil
 }
 
+// workDir returns the work dir passed to the dependency.
+func (ref DepRef) workDir(parentWorkDir string) string {
+	switch {
+	case ref.WorkDir == "":
+		return parentWorkDir
+	case filepath.IsAbs(ref.WorkDir) || parentWorkDir == "":
+		return filepath.Clean(ref.WorkDir)
+	default:
+		return filepath.Join(parentWorkDir, ref.WorkDir)
+	}
+}
+
 func validateRequiredDeps(target any) error {
 	v := reflect.ValueOf(target)
 	if v.Kind() != reflect.Ptr || v.IsNil() {
@@ -211,6 +236,9 @@ func findStructDeps(target any) (map[string]DepRef, error) {
 	return out, nil
 }
 
+// mergeDeps overlays config onto inferred. Names are matched like the struct
+// fields they are injected into, so "db" in a config replaces the DB field's
+// tag default.
 func mergeDeps(config map[string]DepRef, inferred map[string]DepRef) map[string]DepRef {
 	if config == nil && inferred == nil {
 		return nil
@@ -218,12 +246,14 @@ func mergeDeps(config map[string]DepRef, inferred map[string]DepRef) map[string]
 	out := make(map[string]DepRef)
 
 	// config first (wins)
+	fields := make(map[string]bool, len(config))
 	for k, v := range config {
 		out[k] = v
+		fields[words.ToCapWords(k)] = true
 	}
 	// fill missing from inferred
 	for k, v := range inferred {
-		if _, exists := out[k]; !exists {
+		if !fields[words.ToCapWords(k)] {
 			out[k] = v
 		}
 	}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bartdeboer/go-core/internal/yaml"
+)
+
+// Description formats accepted by Description.Write.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// Description is the structured result of DescriberOf[Description].
+type Description struct {
+	Kind       string         `json:"kind,omitempty"`
+	Name       string         `json:"name"`
+	Status     string         `json:"status,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Children   []Description  `json:"children,omitempty"`
+}
+
+// Write renders the description in format: FormatTable, FormatJSON or
+// FormatYAML.
+func (d *Description) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case FormatTable, "":
+		return d.WriteTable(w)
+	case FormatJSON:
+		return d.WriteJSON(w)
+	case FormatYAML:
+		return d.WriteYAML(w)
+	}
+	return fmt.Errorf("unknown format %q: want table, json or yaml", format)
+}
+
+// WriteTable renders the description as aligned columns: the item, its
+// attributes and a row per child, in sections separated by blank lines.
+func (d *Description) WriteTable(w io.Writer) error {
+	sections := [][][]string{{
+		{"KIND", "NAME", "STATUS"},
+		{dash(d.Kind), d.Name, dash(d.Status)},
+	}}
+	if len(d.Attributes) > 0 {
+		rows := [][]string{{"ATTRIBUTE", "VALUE"}}
+		for _, k := range sortedKeys(d.Attributes) {
+			rows = append(rows, []string{k, attributeText(d.Attributes[k])})
+		}
+		sections = append(sections, rows)
+	}
+	if len(d.Children) > 0 {
+		rows := [][]string{{"CHILD", "KIND", "STATUS"}}
+		for _, c := range d.Children {
+			rows = append(rows, []string{c.Name, dash(c.Kind), dash(c.Status)})
+		}
+		sections = append(sections, rows)
+	}
+
+	for i, rows := range sections {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON renders the description as indented JSON.
+func (d *Description) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteYAML renders the description as YAML.
+func (d *Description) WriteYAML(w io.Writer) error {
+	return yaml.Encode(w, d)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// attributeText formats an attribute value for a table cell.
+func attributeText(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case nil:
+		return "-"
+	}
+	return jsonText(v)
+}
+
+// DescribeLegacy adapts a string Describer to DescriberOf[Description].
+// Lines of the form "Key: value" become attributes, with kind, name and
+// status lifted into their fields; other lines are kept in a "text"
+// attribute.
+func DescribeLegacy(d Describer) DescriberOf[Description] {
+	return legacyDescriber{d}
+}
+
+type legacyDescriber struct {
+	d Describer
+}
+
+func (l legacyDescriber) Describe(ctx context.Context, name string) (Description, error) {
+	text, err := l.d.Describe(ctx, name)
+	if err != nil {
+		return Description{}, err
+	}
+	return parseLegacyDescription(name, text), nil
+}
+
+func parseLegacyDescription(name, text string) Description {
+	desc := Description{Name: name, Attributes: make(map[string]any)}
+	var rest []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		// Indented lines and keys with spaces are free text.
+		if !ok || key == "" || strings.ContainsAny(key, " \t") || strings.TrimLeft(line, " \t") != line {
+			if strings.TrimSpace(line) != "" {
+				rest = append(rest, line)
+			}
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "kind":
+			desc.Kind = value
+		case "name":
+			desc.Name = value
+		case "status":
+			desc.Status = value
+		default:
+			desc.Attributes[key] = value
+		}
+	}
+	if len(rest) > 0 {
+		desc.Attributes["text"] = strings.Join(rest, "\n")
+	}
+	if len(desc.Attributes) == 0 {
+		desc.Attributes = nil
+	}
+	return desc
+}
+
+// Describe builds the adapter for adapterID and args like NewAdapter and
+// describes name with it. String Describers are wrapped with DescribeLegacy.
+func (r *Registry) Describe(ctx context.Context, adapterID, name string, args ...string) (*Description, error) {
+	a, err := r.newAdapter(ctx, adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	var d DescriberOf[Description]
+	switch t := a.(type) {
+	case DescriberOf[Description]:
+		d = t
+	case Describer:
+		d = DescribeLegacy(t)
+	default:
+		return nil, fmt.Errorf("adapter %s is not a Describer", adapterID)
+	}
+	desc, err := d.Describe(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("describe %s: %w", name, err)
+	}
+	return &desc, nil
+}
+
+// Describe describes name with an adapter from the default registry.
+func Describe(ctx context.Context, adapterID, name string, args ...string) (*Description, error) {
+	return defaultRegistry.Describe(ctx, adapterID, name, args...)
+}
@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// BucketDescriber returns structured descriptions.
+type BucketDescriber struct{}
+
+func (a *BucketDescriber) Describe(ctx context.Context, name string) (core.Description, error) {
+	return core.Description{
+		Kind:       "bucket",
+		Name:       name,
+		Status:     "ready",
+		Attributes: map[string]any{"region": "eu-west-1", "versioning": true},
+		Children:   []core.Description{{Kind: "object", Name: "index.html"}},
+	}, nil
+}
+
+// TextDescriber only returns text.
+type TextDescriber struct{}
+
+func (a *TextDescriber) Describe(ctx context.Context, name string) (string, error) {
+	return "Kind: volume\nStatus: bound\nSize: 10Gi\n  mounted by web\n", nil
+}
+
+func TestRegistry_Describe(t *testing.T) {
+	core.Register("desc-bucket", func() core.Adapter { return &BucketDescriber{} })
+	core.Register("desc-text", func() core.Adapter { return &TextDescriber{} })
+	if _, err := core.SetDefaultSearchPath(t.TempDir()); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	ctx := context.Background()
+
+	d, err := core.Describe(ctx, "desc-bucket", "assets")
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := d.Write(&buf, core.FormatTable); err != nil {
+		t.Fatalf("WriteTable: %v", err)
+	}
+	wantTable := `KIND    NAME    STATUS
+bucket  assets  ready
+
+ATTRIBUTE   VALUE
+region      eu-west-1
+versioning  true
+
+CHILD       KIND    STATUS
+index.html  object  -
+`
+	if got := buf.String(); got != wantTable {
+		t.Fatalf("table =\n%s\nwant\n%s", got, wantTable)
+	}
+
+	buf.Reset()
+	if err := d.Write(&buf, core.FormatYAML); err != nil {
+		t.Fatalf("WriteYAML: %v", err)
+	}
+	wantYAML := `kind: bucket
+name: assets
+status: ready
+attributes:
+  region: eu-west-1
+  versioning: true
+children:
+- kind: object
+  name: index.html
+`
+	if got := buf.String(); got != wantYAML {
+		t.Fatalf("yaml =\n%s\nwant\n%s", got, wantYAML)
+	}
+
+	legacy, err := core.Describe(ctx, "desc-text", "data")
+	if err != nil {
+		t.Fatalf("Describe legacy: %v", err)
+	}
+	if legacy.Kind != "volume" || legacy.Status != "bound" || legacy.Name != "data" ||
+		legacy.Attributes["Size"] != "10Gi" || legacy.Attributes["text"] != "  mounted by web" {
+		t.Fatalf("legacy description = %+v", legacy)
+	}
+	if err := legacy.Write(&buf, "xml"); err == nil {
+		t.Fatalf("Write xml succeeded")
+	}
+}
@@ -0,0 +1,310 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Change kinds in a SpecDiff.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// FieldChange is one changed leaf of a spec.
+type FieldChange struct {
+	Pointer string `json:"pointer"` // JSON pointer into the spec, e.g. /replicas
+	Kind    string `json:"kind"`    // ChangeAdded, ChangeRemoved or ChangeChanged
+	Old     any    `json:"old,omitempty"`
+	New     any    `json:"new,omitempty"`
+
+	// Redacted is set for resolved secret and output values, which are
+	// compared by digest and never reported.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// SpecDiff compares the effective spec of an adapter request with the spec
+// recorded at its last successful Create or Update.
+type SpecDiff struct {
+	Adapter   string        `json:"adapter"`
+	Item      string        `json:"item,omitempty"`
+	Recorded  bool          `json:"recorded"` // false if never applied
+	AppliedAt time.Time     `json:"applied_at,omitzero"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// Changed reports whether applying the current spec would change anything.
+func (d *SpecDiff) Changed() bool {
+	return !d.Recorded || len(d.Changes) > 0
+}
+
+// WriteText renders the diff one change per line, like a unified diff.
+func (d *SpecDiff) WriteText(w io.Writer) error {
+	header := "adapter: " + d.Adapter
+	if d.Item != "" {
+		header += "\nitem:    " + d.Item
+	}
+	if !d.Recorded {
+		header += "\n(never applied)"
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+	for _, c := range d.Changes {
+		var line string
+		switch c.Kind {
+		case ChangeAdded:
+			line = fmt.Sprintf("+ %s = %s", c.Pointer, jsonText(c.New))
+		case ChangeRemoved:
+			line = fmt.Sprintf("- %s = %s", c.Pointer, jsonText(c.Old))
+		case ChangeChanged:
+			if c.Redacted {
+				line = fmt.Sprintf("~ %s: changed (redacted)", c.Pointer)
+				break
+			}
+			fallthrough
+		default:
+			line = fmt.Sprintf("~ %s: %s -> %s", c.Pointer, jsonText(c.Old), jsonText(c.New))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonText(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// AppliedRecord is what the registry keeps of a successful Create or Update.
+type AppliedRecord struct {
+	Adapter   string          `json:"adapter"`
+	Item      string          `json:"item,omitempty"`
+	Spec      json.RawMessage `json:"spec,omitempty"`
+	AppliedAt time.Time       `json:"applied_at"`
+
+	// RefDigests holds a digest of each resolved $secret and $output value,
+	// by its pointer in the spec.
+	RefDigests map[string]string `json:"ref_digests,omitempty"`
+}
+
+// appliedKeyPrefix keeps applied records apart from the adapters' own state.
+const appliedKeyPrefix = "@applied/"
+
+// Diff compares the effective spec of an adapter request, as Explain reports
+// it, with the spec recorded at its last successful Create or Update. args
+// are interpreted as in NewAdapter. Adapters implementing Differ compute the
+// changes themselves. Secret and output values are left out of the specs;
+// they are compared by digest, and a change is reported redacted.
+func (r *Registry) Diff(ctx context.Context, adapterID string, args ...string) (*SpecDiff, error) {
+	e, err := r.Explain(adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	key, err := r.requestKey(adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	store, err := r.StateStore()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &SpecDiff{Adapter: e.Adapter, Item: e.Item, Changes: []FieldChange{}}
+	var rec AppliedRecord
+	err = store.Load(ctx, appliedKeyPrefix+key, &rec)
+	switch {
+	case err == nil:
+		d.Recorded, d.AppliedAt = true, rec.AppliedAt
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := r.refDigests(ctx, adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if differ, ok := zeroFac().(Differ); ok {
+		changes, err := differ.Diff(ctx, rec.Spec, e.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("diff %s: %w", adapterID, err)
+		}
+		if changes != nil {
+			d.Changes = changes
+		}
+	} else if d.Changes, err = DiffSpecs(rec.Spec, e.Spec); err != nil {
+		return nil, fmt.Errorf("diff %s: %w", adapterID, err)
+	}
+	d.Changes = append(d.Changes, redactedChanges(rec.RefDigests, digests, d.Changes)...)
+	return d, nil
+}
+
+// redactedChanges reports the references whose resolved value changed, except
+// where changes already has the pointer. References added or removed show up
+// in the spec itself.
+func redactedChanges(old, new map[string]string, changes []FieldChange) []FieldChange {
+	var out []FieldChange
+	for _, ptr := range sortedKeys(new) {
+		prev, ok := old[ptr]
+		if !ok || prev == new[ptr] || slices.ContainsFunc(changes, func(c FieldChange) bool { return c.Pointer == ptr }) {
+			continue
+		}
+		out = append(out, FieldChange{Pointer: ptr, Kind: ChangeChanged, Redacted: true})
+	}
+	return out
+}
+
+// refDigests resolves the $secret and $output references in the spec of a
+// request and returns a digest of each value by pointer, item references
+// overriding adapter-level ones. The values themselves are never kept.
+func (r *Registry) refDigests(ctx context.Context, adapterID string, args ...string) (map[string]string, error) {
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return nil, err
+	}
+	meta, itemMeta, err := r.loadMetas(zeroFac(), adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string)
+	for _, m := range []*MetaHeader{meta, itemMeta} {
+		if m == nil || len(m.RawSpec) == 0 {
+			continue
+		}
+		spec, err := decodeJSON(m.RawSpec)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s spec: %w", m.Name, err)
+		}
+		resolvers := map[string]func(ref string) (any, error){
+			secretKey: func(ref string) (any, error) { return r.resolveSecret(ctx, ref) },
+			outputKey: func(ref string) (any, error) { return r.resolveOutput(ctx, "", ref) },
+		}
+		for _, key := range sortedKeys(resolvers) {
+			err := walkRefs(spec, "", key, func(ptr, ref string) error {
+				v, err := resolvers[key](ref)
+				if err != nil {
+					return err
+				}
+				data, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				sum := sha256.Sum256(append([]byte(ptr+"\x00"), data...))
+				digests[ptr] = hex.EncodeToString(sum[:])
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s spec: %w", m.Name, err)
+			}
+		}
+	}
+	return digests, nil
+}
+
+// DiffSpecs returns the leaf-level changes from old to new, ordered by
+// pointer. Arrays are compared as a whole. Empty specs count as {}.
+func DiffSpecs(old, new json.RawMessage) ([]FieldChange, error) {
+	decode := func(data json.RawMessage) (any, error) {
+		if len(data) == 0 || string(data) == "null" {
+			return map[string]any{}, nil
+		}
+		return decodeJSON(data)
+	}
+	ov, err := decode(old)
+	if err != nil {
+		return nil, err
+	}
+	nv, err := decode(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	diffValues(ov, nv, "", &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Pointer < changes[j].Pointer })
+	return changes, nil
+}
+
+func diffValues(old, new any, ptr string, out *[]FieldChange) {
+	om, oldObj := old.(map[string]any)
+	nm, newObj := new.(map[string]any)
+	if oldObj && newObj {
+		for k, ov := range om {
+			nv, ok := nm[k]
+			if !ok {
+				walkLeaves(ov, ptr+jsonPointer(k), func(p string, leaf any) {
+					*out = append(*out, FieldChange{Pointer: p, Kind: ChangeRemoved, Old: leaf})
+				})
+				continue
+			}
+			diffValues(ov, nv, ptr+jsonPointer(k), out)
+		}
+		for k, nv := range nm {
+			if _, ok := om[k]; !ok {
+				walkLeaves(nv, ptr+jsonPointer(k), func(p string, leaf any) {
+					*out = append(*out, FieldChange{Pointer: p, Kind: ChangeAdded, New: leaf})
+				})
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(old, new) {
+		*out = append(*out, FieldChange{Pointer: ptr, Kind: ChangeChanged, Old: old, New: new})
+	}
+}
+
+// recordApplied stores the effective spec of a request after a successful
+// Create or Update.
+func (r *Registry) recordApplied(ctx context.Context, store StateStore, key, adapterID string, args ...string) error {
+	e, err := r.Explain(adapterID, args...)
+	if err != nil {
+		return err
+	}
+	digests, err := r.refDigests(ctx, adapterID, args...)
+	if err != nil {
+		return err
+	}
+	rec := AppliedRecord{Adapter: e.Adapter, Item: e.Item, Spec: e.Spec, AppliedAt: time.Now().UTC(), RefDigests: digests}
+	return store.Save(ctx, appliedKeyPrefix+key, rec)
+}
+
+// requestKey returns the state key of an adapter request.
+func (r *Registry) requestKey(adapterID string, args ...string) (string, error) {
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return "", err
+	}
+	_, itemMeta, err := r.loadMetas(zeroFac(), adapterID, args...)
+	if err != nil {
+		return "", err
+	}
+	return stateKeyFor(adapterID, itemMeta), nil
+}
+
+// Diff compares an adapter request's spec with its last applied spec in the
+// default registry.
+func Diff(ctx context.Context, adapterID string, args ...string) (*SpecDiff, error) {
+	return defaultRegistry.Diff(ctx, adapterID, args...)
+}
@@ -0,0 +1,157 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// ServiceAdp is a Lifecycle adapter counting its calls.
+type ServiceAdp struct {
+	Spec struct {
+		Image    string            `json:"image"`
+		Replicas int               `json:"replicas"`
+		Env      map[string]string `json:"env,omitempty"`
+	}
+	calls []string
+}
+
+func (a *ServiceAdp) ItemConfigPtr(name string) any { return &a.Spec }
+
+func (a *ServiceAdp) Create(ctx context.Context, in ...string) error {
+	a.calls = append(a.calls, "create")
+	return nil
+}
+
+func (a *ServiceAdp) Update(ctx context.Context, in ...string) error {
+	a.calls = append(a.calls, "update")
+	return nil
+}
+
+func (a *ServiceAdp) Delete(ctx context.Context, in ...string) error {
+	a.calls = append(a.calls, "delete")
+	return nil
+}
+
+func TestRegistry_Diff(t *testing.T) {
+	core.Register("diff-svc", func() core.Adapter { return &ServiceAdp{} })
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "svc", "api.json")
+	writeFile(t, cfg, `{"adapter":"diff-svc","spec":{"image":"api:1","replicas":2}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	d, err := core.Diff(ctx, "diff-svc", "svc/api")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if d.Recorded || !d.Changed() || len(d.Changes) != 2 {
+		t.Fatalf("diff before create = %+v", d)
+	}
+
+	if err := core.Create(ctx, "diff-svc", "svc/api"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d, err = core.Diff(ctx, "diff-svc", "svc/api"); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if !d.Recorded || d.Changed() {
+		t.Fatalf("diff after create = %+v", d)
+	}
+
+	writeFile(t, cfg, `{"adapter":"diff-svc","spec":{"image":"api:2","replicas":2,"env":{"LOG":"debug"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if d, err = core.Diff(ctx, "diff-svc", "svc/api"); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []core.FieldChange{
+		{Pointer: "/env/LOG", Kind: core.ChangeAdded, New: "debug"},
+		{Pointer: "/image", Kind: core.ChangeChanged, Old: "api:1", New: "api:2"},
+	}
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Fatalf("changes = %+v, want %+v", d.Changes, want)
+	}
+
+	if err := core.Delete(ctx, "diff-svc", "svc/api"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if d, err = core.Diff(ctx, "diff-svc", "svc/api"); err != nil || d.Recorded {
+		t.Fatalf("diff after delete = %+v, %v", d, err)
+	}
+}
+
+func TestDiffSpecs(t *testing.T) {
+	changes, err := core.DiffSpecs(
+		json.RawMessage(`{"a":1,"b":{"c":[1,2]},"d":true}`),
+		json.RawMessage(`{"a":1,"b":{"c":[1,3]}}`),
+	)
+	if err != nil {
+		t.Fatalf("DiffSpecs: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Pointer != "/b/c" || changes[1].Kind != core.ChangeRemoved {
+		t.Fatalf("changes = %+v", changes)
+	}
+}
+
+func TestRegistry_DiffRedactsSecretChanges(t *testing.T) {
+	id := runID("diff-secret-svc")
+	core.Register(id, func() core.Adapter { return &ServiceAdp{} })
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "svc", "api.json"), fmt.Sprintf(`{"adapter":%q,"spec":{"image":"api:1","env":{"TOKEN":{"$secret":"env:DIFF_TOKEN"}}}}`, id))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	t.Setenv("DIFF_TOKEN", "first-token-value")
+	if err := core.Create(ctx, id, "svc/api"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d, err := core.Diff(ctx, id, "svc/api"); err != nil || d.Changed() {
+		t.Fatalf("diff after create = %+v, %v", d, err)
+	}
+
+	t.Setenv("DIFF_TOKEN", "second-token-value")
+	d, err := core.Diff(ctx, id, "svc/api")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []core.FieldChange{{Pointer: "/env/TOKEN", Kind: core.ChangeChanged, Redacted: true}}
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Fatalf("changes = %+v, want %+v", d.Changes, want)
+	}
+	var out strings.Builder
+	if err := d.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !strings.Contains(out.String(), "~ /env/TOKEN: changed (redacted)") || strings.Contains(out.String(), "token-value") {
+		t.Fatalf("WriteText = %q", out.String())
+	}
+
+	err = filepath.WalkDir(filepath.Join(dir, core.StateDir), func(path string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(data), "token-value") {
+			t.Errorf("%s holds the secret: %s", path, data)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk state: %v", err)
+	}
+}
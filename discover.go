@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultProjectMarkers are the markers DiscoverSearchPath looks for when
+// none are given. A trailing slash only matches directories.
+var DefaultProjectMarkers = []string{".core/", "core.json", ".git"}
+
+// configDir is the directory inside a project root that holds its configs.
+const configDir = ".core"
+
+// projectExcludes are skipped when a project root itself is indexed.
+var projectExcludes = []string{".git/", "node_modules/"}
+
+// FindProjectRoot walks up from start (the current directory when empty) and
+// returns the first directory containing one of the markers, like git finds
+// its repository.
+func FindProjectRoot(start string, markers ...string) (string, error) {
+	if len(markers) == 0 {
+		markers = DefaultProjectMarkers
+	}
+	if start == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		start = wd
+	}
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("resolve %q: %w", start, err)
+	}
+
+	for {
+		for _, m := range markers {
+			name := strings.TrimSuffix(m, "/")
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			if strings.HasSuffix(m, "/") && !info.IsDir() {
+				continue
+			}
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no project root with %s found from %s: %w",
+				strings.Join(markers, ", "), start, os.ErrNotExist)
+		}
+		dir = parent
+	}
+}
+
+// DiscoverSearchPath finds the project root from start (see FindProjectRoot)
+// and installs a SearchMap on its .core directory, or on the root itself when
+// it has none. It returns the root.
+//
+// Indexing the root itself only picks up files with an "adapter" key (see
+// IndexOptions.RequireAdapter), so the project's other JSON files, like
+// package.json, are left out. The root's .coreignore applies, and .git and
+// node_modules are skipped without being read.
+func (r *Registry) DiscoverSearchPath(start string, markers ...string) (string, error) {
+	root, err := FindProjectRoot(start, markers...)
+	if err != nil {
+		return "", err
+	}
+
+	searchRoot := root
+	opts := IndexOptions{RequireAdapter: true, Exclude: projectExcludes}
+	if info, err := os.Stat(filepath.Join(root, configDir)); err == nil && info.IsDir() {
+		searchRoot, opts = filepath.Join(root, configDir), IndexOptions{}
+	}
+	sm, err := newSearchMap(osFS{}, opts, searchRoot)
+	if err != nil {
+		return "", err
+	}
+	r.SetSearchMap(sm)
+
+	Log().Debugf("using project root %s (configs in %s)\n", root, searchRoot)
+	return root, nil
+}
+
+// DiscoverSearchPath configures the default registry from the project root
+// found from start.
+func DiscoverSearchPath(start string, markers ...string) (string, error) {
+	return defaultRegistry.DiscoverSearchPath(start, markers...)
+}
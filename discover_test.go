@@ -0,0 +1,86 @@
+package core_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// DiscoveredAdp records the work dir it is given.
+type DiscoveredAdp struct{ WorkDir string }
+
+func (a *DiscoveredAdp) SetWorkDir(p string) { a.WorkDir = p }
+
+func TestDiscoverSearchPath(t *testing.T) {
+	core.Register("discovered-adp", func() core.Adapter { return &DiscoveredAdp{} })
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	writeFile(t, filepath.Join(root, ".core", "discovered-adp.json"), `{"work_dir":".."}`)
+	deep := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	got, err := core.DiscoverSearchPath(deep)
+	if err != nil {
+		t.Fatalf("DiscoverSearchPath: %v", err)
+	}
+	if got != root {
+		t.Fatalf("root = %s, want %s", got, root)
+	}
+	a, err := core.NewAdapterAs[*DiscoveredAdp]("discovered-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.WorkDir != root {
+		t.Fatalf("WorkDir = %q, want project root %q", a.WorkDir, root)
+	}
+
+	// A file marker that is required to be a directory doesn't match.
+	writeFile(t, filepath.Join(root, "src", "marker"), "")
+	if got, err := core.FindProjectRoot(deep, "marker/", ".core/"); err != nil || got != root {
+		t.Fatalf("FindProjectRoot = %s, %v; want %s", got, err, root)
+	}
+	if got, err := core.FindProjectRoot(deep, "marker"); err != nil || got != filepath.Join(root, "src") {
+		t.Fatalf("FindProjectRoot(marker) = %s, %v", got, err)
+	}
+	if _, err := core.FindProjectRoot(deep, "no-such-marker-anywhere"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("FindProjectRoot without match: err = %v, want ErrNotExist", err)
+	}
+}
+
+func TestDiscoverSearchPath_MarkerRootOnlyIndexesAdapters(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "package.json"), `{"name":"web","version":"1.0.0"}`)
+	writeFile(t, filepath.Join(root, "deploy", "web.json"), `{"adapter":"discovered-adp"}`)
+	// Skipped by default, or by the project's .coreignore.
+	writeFile(t, filepath.Join(root, ".git", "hooks.json"), `{"adapter":"discovered-adp"}`)
+	writeFile(t, filepath.Join(root, "node_modules", "pkg", "cfg.json"), `{"adapter":"discovered-adp"}`)
+	writeFile(t, filepath.Join(root, "vendor", "cfg.json"), `{"adapter":"discovered-adp"}`)
+	writeFile(t, filepath.Join(root, ".coreignore"), "vendor/\n")
+
+	if _, err := core.DiscoverSearchPath(root); err != nil {
+		t.Fatalf("DiscoverSearchPath: %v", err)
+	}
+	metas, err := core.Query("")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	var got []string
+	for _, m := range metas {
+		got = append(got, m.Name)
+	}
+	if want := []string{"web"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("configs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value source kinds used in explanations.
+const (
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+)
+
+// ValueSource describes where an effective config value came from.
+type ValueSource struct {
+	Kind    string `json:"kind"`              // SourceFile, SourceEnv or SourceDefault
+	Path    string `json:"path,omitempty"`    // file path or env var name
+	Pointer string `json:"pointer,omitempty"` // JSON pointer within the file or env value
+}
+
+func (s ValueSource) String() string {
+	switch s.Kind {
+	case SourceFile, SourceEnv:
+		return fmt.Sprintf("%s %s#%s", s.Kind, s.Path, s.Pointer)
+	}
+	return s.Kind
+}
+
+// ExplainedValue is a single effective leaf value annotated with its source.
+type ExplainedValue struct {
+	Pointer string      `json:"pointer"` // JSON pointer in the effective config, e.g. /spec/foo
+	Value   any         `json:"value"`
+	Source  ValueSource `json:"source"`
+}
+
+// Explanation is the effective configuration of an adapter request.
+type Explanation struct {
+	Adapter      string            `json:"adapter"`
+	Item         string            `json:"item,omitempty"`
+	WorkDir      string            `json:"work_dir,omitempty"`
+	Dependencies map[string]DepRef `json:"dependencies,omitempty"`
+	Spec         json.RawMessage   `json:"spec,omitempty"`
+	Values       []ExplainedValue  `json:"values"`
+}
+
+// Explain returns the effective merged spec, dependencies and work dir for an
+// adapter request, without caching or hydrating the adapter. args are
+// interpreted the same way as in NewAdapter.
+func (r *Registry) Explain(adapterID string, args ...string) (*Explanation, error) {
+	if r.searchMap == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return nil, err
+	}
+	zero := zeroFac()
+
+	meta, itemMeta, err := r.loadMetas(zero, adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Secrets are never revealed in explanations, and outputs would need the
+	// referenced adapters to be built.
+	redactedMetas, err := resolveSecretRefs(redactSecretRef, meta, itemMeta)
+	if err != nil {
+		return nil, err
+	}
+	if redactedMetas, err = resolveRefs(outputKey, omitOutputRef, redactedMetas...); err != nil {
+		return nil, err
+	}
+	if err := applyConfig(zero, adapterID, redactedMetas[0], redactedMetas[1], false); err != nil {
+		return nil, err
+	}
+
+	// Item sources first: they override the adapter-level ones.
+	var files []explainFile
+	for _, m := range []*MetaHeader{itemMeta, meta} {
+		if m == nil {
+			continue
+		}
+		for _, src := range m.Sources {
+			data, err := r.searchMap.ReadSource(src)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", src.Path, err)
+			}
+			v, err := decodeJSON(data)
+			if err != nil {
+				return nil, fmt.Errorf("decode %s: %w", src.Path, err)
+			}
+			files = append(files, explainFile{path: src.Path, value: v})
+		}
+	}
+
+	e := &Explanation{Adapter: strings.ToLower(adapterID)}
+	if itemMeta != nil {
+		e.Item = itemMeta.Name
+	}
+
+	// Work dir.
+	e.WorkDir = resolveWorkDir("", meta, itemMeta)
+	if e.WorkDir != "" {
+		e.Values = append(e.Values, ExplainedValue{
+			Pointer: "/work_dir",
+			Value:   e.WorkDir,
+			Source:  workDirSource(files, meta, itemMeta),
+		})
+	}
+
+	// Dependencies.
+	inferred, err := findStructDeps(zero)
+	if err != nil {
+		return nil, err
+	}
+	var itemDeps map[string]DepRef
+	if itemMeta != nil {
+		itemDeps = itemMeta.Dependencies
+	}
+	var adapterDeps map[string]DepRef
+	if meta != nil {
+		adapterDeps = meta.Dependencies
+	}
+	e.Dependencies = mergeDeps(itemDeps, mergeDeps(adapterDeps, inferred))
+	for name, ref := range e.Dependencies {
+		ptr := jsonPointer("dependencies", name)
+		e.Values = append(e.Values, ExplainedValue{
+			Pointer: ptr,
+			Value:   ref,
+			Source:  fileSource(files, ptr),
+		})
+	}
+
+	// Spec.
+	spec, err := effectiveSpec(zero, itemMeta)
+	if err != nil {
+		return nil, fmt.Errorf("explain %s spec: %w", adapterID, err)
+	}
+	if spec != nil {
+		e.Spec = spec
+		v, err := decodeJSON(spec)
+		if err != nil {
+			return nil, err
+		}
+		walkLeaves(v, "/spec", func(ptr string, leaf any) {
+			e.Values = append(e.Values, ExplainedValue{
+				Pointer: ptr,
+				Value:   leaf,
+				Source:  fileSource(files, ptr),
+			})
+		})
+	}
+
+	sort.SliceStable(e.Values, func(i, j int) bool {
+		return e.Values[i].Pointer < e.Values[j].Pointer
+	})
+	return e, nil
+}
+
+// WriteText renders the explanation as aligned text, one leaf per line.
+func (e *Explanation) WriteText(w io.Writer) error {
+	header := "adapter: " + e.Adapter
+	if e.Item != "" {
+		header += "\nitem:    " + e.Item
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+
+	width := 0
+	for _, v := range e.Values {
+		width = max(width, len(v.Pointer))
+	}
+	for _, v := range e.Values {
+		val, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%-*s = %s  (%s)\n", width, v.Pointer, val, v.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON renders the explanation as indented JSON.
+func (e *Explanation) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// explainFile is a decoded config file used to locate value sources.
+type explainFile struct {
+	path  string
+	value any
+}
+
+// effectiveSpec marshals the adapter config with the item config overlaid.
+func effectiveSpec(adapter Adapter, itemMeta *MetaHeader) ([]byte, error) {
+	var spec []byte
+	if c, ok := adapter.(Configurable); ok {
+		b, err := json.Marshal(c.ConfigPtr())
+		if err != nil {
+			return nil, err
+		}
+		spec = b
+	}
+	if ic, ok := adapter.(ItemConfigurable); ok && itemMeta != nil {
+		b, err := json.Marshal(ic.ItemConfigPtr(itemMeta.Name))
+		if err != nil {
+			return nil, err
+		}
+		return mergeJSON(spec, b)
+	}
+	return spec, nil
+}
+
+func workDirSource(files []explainFile, metas ...*MetaHeader) ValueSource {
+	for i := len(metas) - 1; i >= 0; i-- {
+		m := metas[i]
+		if m == nil || m.WorkDir == "" {
+			continue
+		}
+		if _, ok := workDirMap[m.Name]; ok {
+			return ValueSource{Kind: SourceEnv, Path: "CORE_WORK_DIR_MAP", Pointer: jsonPointer(m.Name)}
+		}
+		break
+	}
+	return fileSource(files, "/work_dir")
+}
+
+// fileSource returns the first file (in precedence order) that sets ptr.
+func fileSource(files []explainFile, ptr string) ValueSource {
+	for _, f := range files {
+		if _, ok := lookupPointer(f.value, ptr); ok {
+			return ValueSource{Kind: SourceFile, Path: f.path, Pointer: ptr}
+		}
+	}
+	return ValueSource{Kind: SourceDefault}
+}
+
+// walkLeaves calls fn for every non-object value (or empty object) in v.
+func walkLeaves(v any, ptr string, fn func(ptr string, leaf any)) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		fn(ptr, v)
+		return
+	}
+	for k, child := range m {
+		walkLeaves(child, ptr+jsonPointer(k), fn)
+	}
+}
+
+// lookupPointer finds the value at ptr in a decoded JSON document. Object keys
+// are matched case-insensitively, like encoding/json does when decoding.
+func lookupPointer(v any, ptr string) (any, bool) {
+	if ptr == "" {
+		return v, true
+	}
+	for _, tok := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		if arr, ok := v.([]any); ok {
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(arr) {
+				return nil, false
+			}
+			v = arr[i]
+			continue
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		child, ok := m[tok]
+		if !ok {
+			for k, c := range m {
+				if strings.EqualFold(k, tok) {
+					child, ok = c, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		v = child
+	}
+	return v, true
+}
+
+// jsonPointer builds an RFC 6901 pointer from unescaped tokens.
+func jsonPointer(tokens ...string) string {
+	var b strings.Builder
+	esc := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(esc.Replace(t))
+	}
+	return b.String()
+}
+
+// Explain returns the effective configuration using the default registry.
+func Explain(adapterID string, args ...string) (*Explanation, error) {
+	return defaultRegistry.Explain(adapterID, args...)
+}
@@ -0,0 +1,31 @@
+// Package health serves the status of a core registry's adapters over HTTP,
+// for /healthz style endpoints:
+//
+//	http.Handle("/healthz", health.Handler(core.DefaultRegistry(), 5*time.Second))
+//
+// It is kept out of package core so programs that don't serve health checks
+// don't link net/http.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// Handler serves r.CheckStatus as JSON, with 200 when ready and 503
+// otherwise. timeout applies to each adapter, as in CheckStatus.
+func Handler(r *core.Registry, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.CheckStatus(req.Context(), timeout)
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	})
+}
@@ -0,0 +1,51 @@
+package health_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+	"github.com/bartdeboer/go-core/health"
+)
+
+// hungAdp answers only when its deadline passes or the test is over.
+type hungAdp struct{ release chan struct{} }
+
+func (a *hungAdp) Status(ctx context.Context) (core.Status, error) {
+	select {
+	case <-ctx.Done():
+		return core.Status{}, ctx.Err()
+	case <-a.release:
+		return core.Status{State: core.StatusReady}, nil
+	}
+}
+
+var runSeq atomic.Int64
+
+func TestHandler(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	id := fmt.Sprintf("health-hung-%d", runSeq.Add(1))
+	core.Register(id, func() core.Adapter { return &hungAdp{release: release} })
+	if _, err := core.SetDefaultSearchPath(t.TempDir()); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if _, err := core.NewAdapter(id); err != nil {
+		t.Fatalf("NewAdapter: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	health.Handler(core.DefaultRegistry(), 50*time.Millisecond).ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("healthz code = %d, want 503", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is read from the top of every search root. It uses gitignore
+// syntax; paths are relative to the root.
+const IgnoreFile = ".coreignore"
+
+// IndexOptions controls which files a SearchMap indexes.
+type IndexOptions struct {
+	// Include limits indexing to files matching at least one glob. Globs
+	// are slash-separated, relative to the root, and support "**". A glob
+	// without a slash matches the file name at any depth.
+	Include []string
+	// Exclude skips files and directories matching any pattern. Patterns
+	// use gitignore syntax and apply after the root's .coreignore.
+	Exclude []string
+	// RequireAdapter only indexes files whose top-level object has an
+	// "adapter" key, skipping unrelated JSON.
+	RequireAdapter bool
+}
+
+// SetIndexOptions replaces the index options and re-indexes all roots.
+func (sm *SearchMap) SetIndexOptions(opts IndexOptions) error {
+	for _, g := range opts.Include {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("include %q: %w", g, err)
+		}
+	}
+	sm.mu.Lock()
+	sm.opts = opts
+	sm.mu.Unlock()
+	return sm.Reindex()
+}
+
+// walkConfigs calls fn for every config file under the layer's root that
+// passes the ignore rules and opts. It reports whether the root is missing.
+func (l searchLayer) walkConfigs(opts IndexOptions, fn func(cfgPath, rel string, d fs.DirEntry) error) (missing bool, err error) {
+	local := isLocalFS(l.fs)
+
+	var rules ignoreRules
+	data, err := l.fs.ReadFile(l.join(IgnoreFile))
+	switch {
+	case err == nil:
+		rules = parseIgnore(data)
+	case !errors.Is(err, fs.ErrNotExist):
+		return false, fmt.Errorf("read %s: %w", l.join(IgnoreFile), err)
+	}
+	rules = append(rules, parseIgnore([]byte(strings.Join(opts.Exclude, "\n")))...)
+
+	err = l.fs.WalkDir(l.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == l.path && errors.Is(err, fs.ErrNotExist) {
+				missing = true
+				return fs.SkipDir
+			}
+			return err
+		}
+		if p == l.path {
+			return nil
+		}
+
+		cfgPath, rel, err := l.relPath(p, local)
+		if err != nil {
+			return err
+		}
+		slashRel := filepath.ToSlash(rel)
+		if d.IsDir() && d.Name() == StateDir {
+			return fs.SkipDir
+		}
+		if rules.ignored(slashRel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(d.Name()) != ".json" {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, slashRel) {
+			return nil
+		}
+		if opts.RequireAdapter {
+			ok, err := hasAdapterKey(l.fs, p)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				Log().Debugf("skipping %s: no adapter key\n", cfgPath)
+				return nil
+			}
+		}
+		return fn(cfgPath, rel, d)
+	})
+	return missing, err
+}
+
+// join returns name inside the layer's root.
+func (l searchLayer) join(name string) string {
+	return Layer{FS: l.fs, Root: l.path}.join(name)
+}
+
+// hasAdapterKey reports whether the file's top-level object has an adapter key.
+func hasAdapterKey(fsys FileSystem, p string) (bool, error) {
+	data, err := fsys.ReadFile(p)
+	if err != nil {
+		return false, fmt.Errorf("read %s: %w", p, err)
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return false, nil
+	}
+	for k := range top {
+		if strings.EqualFold(k, "adapter") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ignoreRule is one line of a gitignore-style file.
+type ignoreRule struct {
+	segments []string // pattern split on "/", anchored to the root
+	negate   bool
+	dirOnly  bool
+}
+
+type ignoreRules []ignoreRule
+
+// parseIgnore parses gitignore syntax: comments, "!" negation, trailing "/"
+// for directories, a leading or inner "/" anchoring to the root, and "**".
+func parseIgnore(data []byte) ignoreRules {
+	var out ignoreRules
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		rule.segments = strings.Split(line, "/")
+		out = append(out, rule)
+	}
+	return out
+}
+
+// ignored reports whether the slash-separated path rel is ignored. The last
+// matching rule wins.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	parts := strings.Split(rel, "/")
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, parts) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchAnyGlob reports whether rel matches one of the include globs.
+func matchAnyGlob(globs []string, rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, g := range globs {
+		g = strings.TrimPrefix(g, "/")
+		if !strings.Contains(g, "/") {
+			g = "**/" + g
+		}
+		if matchSegments(strings.Split(g, "/"), parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments.
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
@@ -1,6 +1,9 @@
 package core
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 // Configurable is called with configurations that match the adapter ID
 type Configurable interface {
@@ -15,6 +18,13 @@ type ItemConfigurable interface {
 	ItemConfigPtr(name string) any
 }
 
+// StrictConfigurable lets an adapter opt in or out of strict config decoding,
+// overriding the registry setting. In strict mode unknown keys in the config
+// file or its spec are reported as errors.
+type StrictConfigurable interface {
+	StrictConfig() bool
+}
+
 // Executor is a generic "do one unit of work" role.
 // It is intentionally minimal so it can represent pipeline steps,
 // tasks, jobs, commands, etc. in higher-level systems.
@@ -77,6 +87,12 @@ type Describer interface {
 	Describe(ctx context.Context, name string) (string, error)
 }
 
+// DescriberOf is the structured variant of Describer, typically
+// DescriberOf[Description]. Use DescribeLegacy to wrap a string Describer.
+type DescriberOf[T any] interface {
+	Describe(ctx context.Context, name string) (T, error)
+}
+
 type Browser interface {
 	Lister
 	Describer
@@ -129,6 +145,63 @@ type Pruner interface {
 	Prune(ctx context.Context, filter string) error
 }
 
+// SecretProvider resolves secret references used in specs:
+//
+//	"password": {"$secret": "<provider>:<ref>"}
+//
+// where <provider> is the adapter ID or item config name of the provider.
+type SecretProvider interface {
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// Reconfigurable adapters are handed their new config when their config files
+// change while watching, instead of being rebuilt. meta and itemMeta are the
+// adapter-level and item-level configs and may be nil.
+type Reconfigurable interface {
+	Reconfigure(ctx context.Context, meta, itemMeta *MetaHeader) error
+}
+
+// Outputter exposes values an adapter produced, such as the IDs or endpoints
+// of created resources, to $output references in other configs.
+type Outputter interface {
+	Outputs(ctx context.Context) (map[string]any, error)
+}
+
+// StateAware adapters are handed the state of their item before they are
+// hydrated, so Create, Update and Delete can be made idempotent:
+//
+//	unlock, err := a.state.Lock(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer unlock()
+//	var rec record
+//	if err := a.state.Load(ctx, &rec); err == nil {
+//		return nil // already created
+//	}
+type StateAware interface {
+	SetState(state *State)
+}
+
+// Differ adapters compare the spec recorded at their last Create or Update
+// with the desired one themselves, e.g. to ignore fields that don't need an
+// Update. recorded is nil if the item was never applied.
+type Differ interface {
+	Diff(ctx context.Context, recorded, desired json.RawMessage) ([]FieldChange, error)
+}
+
+// Planner adapters decide themselves what Apply would do with their item, e.g.
+// by comparing their spec with the live resource.
+type Planner interface {
+	Plan(ctx context.Context, in ...string) (*PlannedAction, error)
+}
+
+// StatusReporter adapters report their health after Hydrate, for
+// Registry.CheckStatus and health endpoints.
+type StatusReporter interface {
+	Status(ctx context.Context) (Status, error)
+}
+
 type Hydrater interface {
 	Hydrate(ctx context.Context) error
 }
@@ -0,0 +1,213 @@
+// Package yaml encodes JSON-compatible values as YAML. Values are marshaled
+// to JSON first, so json struct tags and Marshalers apply, and object keys
+// keep their JSON order.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Marshal returns the YAML encoding of v.
+func Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decode(dec)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeNode(&buf, n, 0)
+	return buf.Bytes(), nil
+}
+
+// Encode writes the YAML encoding of v to w.
+func Encode(w io.Writer, v any) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Object is a JSON object that keeps its key order. It marshals to JSON in
+// order, and Marshal writes it in order.
+type Object []Member
+
+// Member is one key and value of an Object.
+type Member struct {
+	Key   string
+	Value any
+}
+
+// MarshalJSON encodes the members in order.
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func decode(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := Object{}
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decode(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, Member{Key: k.(string), Value: v})
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				v, err := decode(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	}
+	return tok, nil
+}
+
+func writeNode(buf *bytes.Buffer, n any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch t := n.(type) {
+	case Object:
+		if len(t) == 0 {
+			buf.WriteString(pad + "{}\n")
+			return
+		}
+		for _, m := range t {
+			buf.WriteString(pad + scalar(m.Key) + ":")
+			writeValue(buf, m.Value, indent)
+		}
+	case []any:
+		if len(t) == 0 {
+			buf.WriteString(pad + "[]\n")
+			return
+		}
+		for _, v := range t {
+			buf.WriteString(pad + "-")
+			writeItem(buf, v, indent)
+		}
+	default:
+		buf.WriteString(pad + scalar(n) + "\n")
+	}
+}
+
+// writeValue writes the value after "key:".
+func writeValue(buf *bytes.Buffer, v any, indent int) {
+	switch t := v.(type) {
+	case Object:
+		if len(t) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeNode(buf, t, indent+1)
+	case []any:
+		if len(t) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		// Sequences in mappings are not indented, as most tools write them.
+		buf.WriteString("\n")
+		writeNode(buf, t, indent)
+	default:
+		buf.WriteString(" " + scalar(v) + "\n")
+	}
+}
+
+// writeItem writes the value after "-", putting the first member of an
+// object on the dash line.
+func writeItem(buf *bytes.Buffer, v any, indent int) {
+	switch t := v.(type) {
+	case Object:
+		if len(t) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		var nested bytes.Buffer
+		writeNode(&nested, t, indent+1)
+		buf.WriteString(" " + strings.TrimPrefix(nested.String(), strings.Repeat("  ", indent+1)))
+	case []any:
+		if len(t) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeNode(buf, t, indent+1)
+	default:
+		buf.WriteString(" " + scalar(v) + "\n")
+	}
+}
+
+var (
+	plain    = regexp.MustCompile(`^[A-Za-z_./][A-Za-z0-9_./ -]*$`)
+	reserved = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|y|n|null|~|\.inf|\.nan|\.\.\.)$`)
+	// number matches the YAML 1.2 core schema's ints and floats.
+	number = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$|^0o[0-7]+$|^0x[0-9a-fA-F]+$`)
+)
+
+func scalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(t)
+	case json.Number:
+		return t.String()
+	case string:
+		if plain.MatchString(t) && !reserved.MatchString(t) && !number.MatchString(t) && !strings.HasSuffix(t, " ") && !strings.Contains(t, " -") {
+			return t
+		}
+		// JSON string escapes are valid in YAML double-quoted scalars.
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
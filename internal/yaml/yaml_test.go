@@ -0,0 +1,89 @@
+package yaml_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bartdeboer/go-core/internal/yaml"
+)
+
+func TestMarshal_StringQuoting(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"eu-west-1", "eu-west-1"},
+		{"path/to/file.json", "path/to/file.json"},
+		{"two words", "two words"},
+		{"", `""`},
+		// Values a YAML reader would not read back as this string.
+		{"true", `"true"`},
+		{"No", `"No"`},
+		{"null", `"null"`},
+		{"~", `"~"`},
+		{".inf", `".inf"`},
+		{".NaN", `".NaN"`},
+		{"...", `"..."`},
+		{"42", `"42"`},
+		{"-1", `"-1"`},
+		{"1.5", `"1.5"`},
+		{".5", `".5"`},
+		{".5e3", `".5e3"`},
+		{"1e9", `"1e9"`},
+		{"0x1F", `"0x1F"`},
+		{"0o17", `"0o17"`},
+		// Indicators and whitespace.
+		{"key: value", `"key: value"`},
+		{"# comment", `"# comment"`},
+		{"- item", `"- item"`},
+		{"a -b", `"a -b"`},
+		{"trailing ", `"trailing "`},
+		{"line\nbreak", `"line\nbreak"`},
+		{"*alias", `"*alias"`},
+	}
+	for _, tt := range tests {
+		got, err := yaml.Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", tt.in, err)
+		}
+		if string(got) != tt.want+"\n" {
+			t.Errorf("Marshal(%q) = %q, want %q", tt.in, got, tt.want+"\n")
+		}
+	}
+}
+
+func TestMarshal_Document(t *testing.T) {
+	v := map[string]any{
+		"name":  "web",
+		"ratio": ".5",
+		"ports": []int{80, 443},
+		"env":   map[string]string{},
+	}
+	got, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := "env: {}\nname: web\nports:\n- 80\n- 443\nratio: \".5\"\n"
+	if string(got) != want {
+		t.Fatalf("Marshal = %q, want %q", got, want)
+	}
+}
+
+func TestObject_KeepsOrder(t *testing.T) {
+	obj := yaml.Object{{Key: "zone", Value: "b"}, {Key: "id", Value: 1}}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if got, want := string(data), `{"zone":"b","id":1}`; got != want {
+		t.Fatalf("JSON = %s, want %s", got, want)
+	}
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(out), "zone: b\nid: 1\n"; got != want {
+		t.Fatalf("YAML = %q, want %q", got, want)
+	}
+}
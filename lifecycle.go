@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Lifecycle actions run through the registry.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Create builds the adapter for a request and calls its Create under the
+// item's lock. On success the effective spec is recorded for Diff and, for
+// Outputters, the outputs are persisted.
+func (r *Registry) Create(ctx context.Context, adapterID string, args ...string) error {
+	return r.runAction(ctx, ActionCreate, adapterID, args...)
+}
+
+// Update is Create for Updaters.
+func (r *Registry) Update(ctx context.Context, adapterID string, args ...string) error {
+	return r.runAction(ctx, ActionUpdate, adapterID, args...)
+}
+
+// Delete builds the adapter for a request and calls its Delete under the
+// item's lock. On success the recorded spec and outputs are dropped.
+func (r *Registry) Delete(ctx context.Context, adapterID string, args ...string) error {
+	return r.runAction(ctx, ActionDelete, adapterID, args...)
+}
+
+func (r *Registry) runAction(ctx context.Context, action, adapterID string, args ...string) error {
+	a, err := r.newAdapter(ctx, adapterID, args...)
+	if err != nil {
+		return err
+	}
+	var run func(ctx context.Context, in ...string) error
+	switch action {
+	case ActionCreate:
+		if c, ok := a.(Creater); ok {
+			run = c.Create
+		}
+	case ActionUpdate:
+		if u, ok := a.(Updater); ok {
+			run = u.Update
+		}
+	case ActionDelete:
+		if d, ok := a.(Deleter); ok {
+			run = d.Delete
+		}
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if run == nil {
+		return fmt.Errorf("adapter %s does not support %s", adapterID, action)
+	}
+
+	key, err := r.requestKey(adapterID, args...)
+	if err != nil {
+		return err
+	}
+	store, err := r.StateStore()
+	if err != nil {
+		return err
+	}
+	// The applied record's lock, so adapters can still take their own.
+	unlock, err := store.Lock(ctx, appliedKeyPrefix+key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	Log().Infof("%s %s\n", action, key)
+	if err := run(ctx, args...); err != nil {
+		return fmt.Errorf("%s %s: %w", action, key, err)
+	}
+
+	if action == ActionDelete {
+		if err := store.Delete(ctx, appliedKeyPrefix+key); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if _, ok := a.(Outputter); ok {
+			return r.ForgetOutputs(key)
+		}
+		return nil
+	}
+
+	if err := r.recordApplied(ctx, store, key, adapterID, args...); err != nil {
+		return fmt.Errorf("record %s: %w", key, err)
+	}
+	if o, ok := a.(Outputter); ok {
+		outputs, err := o.Outputs(ctx)
+		if err != nil {
+			return fmt.Errorf("outputs of %s: %w", key, err)
+		}
+		return r.saveOutputs(key, adapterID, outputs)
+	}
+	return nil
+}
+
+// Create runs Create for an adapter request in the default registry.
+func Create(ctx context.Context, adapterID string, args ...string) error {
+	return defaultRegistry.Create(ctx, adapterID, args...)
+}
+
+// Update runs Update for an adapter request in the default registry.
+func Update(ctx context.Context, adapterID string, args ...string) error {
+	return defaultRegistry.Update(ctx, adapterID, args...)
+}
+
+// Delete runs Delete for an adapter request in the default registry.
+func Delete(ctx context.Context, adapterID string, args ...string) error {
+	return defaultRegistry.Delete(ctx, adapterID, args...)
+}
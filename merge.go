@@ -0,0 +1,64 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// mergeJSON deep-merges overlay into base and returns the re-encoded result.
+// Objects are merged key by key; any other value in overlay replaces the one
+// in base.
+func mergeJSON(base, overlay []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(base)) == 0 {
+		return overlay, nil
+	}
+	if len(bytes.TrimSpace(overlay)) == 0 {
+		return base, nil
+	}
+
+	b, err := decodeJSON(base)
+	if err != nil {
+		return nil, err
+	}
+	o, err := decodeJSON(overlay)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValues(b, o))
+}
+
+func mergeValues(base, overlay any) any {
+	bm, ok := base.(map[string]any)
+	if !ok {
+		return overlay
+	}
+	om, ok := overlay.(map[string]any)
+	if !ok {
+		return overlay
+	}
+
+	out := make(map[string]any, len(bm)+len(om))
+	for k, v := range bm {
+		out[k] = v
+	}
+	for k, v := range om {
+		if existing, ok := out[k]; ok {
+			out[k] = mergeValues(existing, v)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// decodeJSON decodes data into generic values, keeping numbers intact.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
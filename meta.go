@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 )
 
 var (
 	// workDirMap overrides work directories by name, filled from CORE_WORK_DIR_MAP env.
+	// Deprecated: use a PathMapper (CORE_PATH_MAP), which maps by path prefix.
 	workDirMap = map[string]string{}
 )
 
@@ -21,8 +25,9 @@ type DepRef struct {
 	Name    string   `json:"name,omitempty"` // fallback on config name
 	Args    []string `json:"args,omitempty"` // extra CLI-style args
 
-	// Optional custom override for the dependency's context.
-	// Context string `json:"context,omitempty"`
+	// Optional override of the dependency's work dir. Relative paths are
+	// relative to the parent's work dir.
+	WorkDir string `json:"work_dir,omitempty"`
 }
 
 // The Context is managed by the system to ensure those paths are adjusted
@@ -32,16 +37,62 @@ type MetaHeader struct {
 	Name         string            `json:"name"` // fallback on filename
 	APIVersion   string            `json:"api_version"`
 	Adapter      string            `json:"adapter,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
 	Dependencies map[string]DepRef `json:"dependencies"`
 	RawSpec      json.RawMessage   `json:"spec"`     // adapter-specific payload
 	WorkDir      string            `json:"work_dir"` // project path (rel or abs)
+
+	// Sources lists the files this header was loaded from, highest precedence first.
+	Sources []ConfigSource `json:"-"`
+
+	refs []string // files of configs resolved through $ref
+
+	// The header as loaded, for Save to write only what changed since.
+	loaded        json.RawMessage
+	loadedWorkDir string
+}
+
+// ConfigSource records where a config file was found.
+type ConfigSource struct {
+	Root string // search root the file was indexed under
+	Key  string // relative key (no .json)
+	Path string // absolute path, or the virtual path on non-OS filesystems
+
+	layer int // index of the root in SearchMap.layers
 }
 
+// searchLayer is a search root on a specific filesystem.
+type searchLayer struct {
+	fs   FileSystem
+	path string
+}
+
+// searchRoot is the index of a single search root.
+type searchRoot struct {
+	layer int
+	path  string
+	short map[string][]string // basename (no .json) -> []paths
+	full  map[string]string   // relative/key (no .json) -> path
+}
+
+// SearchMap indexes config files under one or more roots. Roots are ordered
+// by precedence: the first root wins over the ones after it.
+//
+// Paths on the OS filesystem, and on any FileSystem passed in directly, are
+// absolute. On filesystems adapted with FromFS or stacked with LayeredFS
+// (fs.FS, embed.FS, in-memory) paths stay virtual.
 type SearchMap struct {
-	root  string
-	fs    FileSystem
-	Short map[string][]string // basename (no .json) -> []absolute paths
-	Full  map[string]string   // relative/key (no .json) -> absolute path
+	mu          sync.RWMutex
+	layers      []searchLayer
+	roots       []*searchRoot
+	workDirBase string
+	opts        IndexOptions
+	migrations  *Migrations
+	pathMapper  *PathMapper
+	entries     []*catalogueEntry   // parsed configs, nil when stale
+	gen         int                 // bumped whenever entries are invalidated
+	Short       map[string][]string // basename (no .json) -> []paths (all roots)
+	Full        map[string]string   // relative/key (no .json) -> path (highest precedence)
 }
 
 func init() {
@@ -51,124 +102,492 @@ func init() {
 	}
 }
 
-func NewSearchMapWithFS(root string, fsys FileSystem) (*SearchMap, error) {
+// NewSearchMapWithRoots indexes every root in order of precedence (highest
+// first). Roots that don't exist are skipped, but at least one must exist. On
+// OS-backed filesystems a leading "~/" expands to the home directory.
+func NewSearchMapWithRoots(fsys FileSystem, roots ...string) (*SearchMap, error) {
+	return newSearchMap(fsys, IndexOptions{}, roots...)
+}
+
+// newSearchMap is NewSearchMapWithRoots indexing with opts from the start.
+func newSearchMap(fsys FileSystem, opts IndexOptions, roots ...string) (*SearchMap, error) {
 	sm := &SearchMap{
-		root:  root,
-		fs:    fsys,
 		Short: make(map[string][]string),
 		Full:  make(map[string]string),
+		opts:  opts,
+	}
+	for _, root := range roots {
+		if isLocalFS(fsys) {
+			expanded, err := expandHome(root)
+			if err != nil {
+				return sm, err
+			}
+			root = expanded
+		}
+		sm.layers = append(sm.layers, expandLayers(fsys, root)...)
+	}
+	if err := sm.Reindex(); err != nil {
+		return sm, err
+	}
+	if len(sm.layers) > 0 && len(sm.roots) == 0 {
+		return sm, fmt.Errorf("core: no search root exists: %s: %w", strings.Join(sm.Roots(), ", "), fs.ErrNotExist)
 	}
+	return sm, nil
+}
+
+// expandHome replaces a leading "~" in p with the user's home directory.
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("expand %s: %w", p, err)
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// NewSearchMapWithFS indexes a single root using fsys.
+func NewSearchMapWithFS(root string, fsys FileSystem) (*SearchMap, error) {
+	return NewSearchMapWithRoots(fsys, root)
+}
+
+// NewSearchMapFS indexes roots inside any io/fs.FS, such as an embed.FS. Roots
+// are slash-separated paths within fsys and default to ".".
+//
+//	//go:embed defaults
+//	var defaults embed.FS
+//
+//	sm, err := core.NewSearchMapFS(defaults, "defaults")
+func NewSearchMapFS(fsys fs.FS, roots ...string) (*SearchMap, error) {
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	cleaned := make([]string, len(roots))
+	for i, root := range roots {
+		cleaned[i] = cleanVirtual(root)
+	}
+	return NewSearchMapWithRoots(FromFS(fsys), cleaned...)
+}
+
+// NewLayeredSearchMap indexes a stack of config trees, highest precedence
+// first. See LayeredFS.
+func NewLayeredSearchMap(layers ...Layer) (*SearchMap, error) {
+	return NewSearchMapWithRoots(LayeredFS(layers), ".")
+}
+
+// expandLayers turns root on fsys into search layers, one per layer of a
+// LayeredFS so that every file keeps its own source.
+func expandLayers(fsys FileSystem, root string) []searchLayer {
+	layered, ok := fsys.(LayeredFS)
+	if !ok {
+		return []searchLayer{{fs: fsys, path: root}}
+	}
+	var out []searchLayer
+	for _, l := range layered {
+		out = append(out, expandLayers(l.FS, l.join(root))...)
+	}
+	return out
+}
+
+// Thin wrapper using osFS. Roots are ordered by precedence, e.g.
+//
+//	core.NewSearchMap("./.core", "~/.config/core", "/etc/core")
+func NewSearchMap(roots ...string) (*SearchMap, error) {
+	return NewSearchMapWithRoots(osFS{}, roots...)
+}
 
-	err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+// Reindex walks all roots again and replaces the Short and Full indexes.
+func (sm *SearchMap) Reindex() error {
+	var roots []*searchRoot
+
+	sm.mu.RLock()
+	opts := sm.opts
+	sm.mu.RUnlock()
+
+	for i, l := range sm.layers {
+		r, err := indexRoot(i, l, opts)
 		if err != nil {
 			return err
 		}
-		if d.IsDir() || filepath.Ext(d.Name()) != ".json" {
-			return nil
+		if r == nil {
+			Log().Debugf("skipping missing search root: %s\n", l.path)
+			continue
 		}
+		roots = append(roots, r)
+	}
 
-		absPath, err := filepath.Abs(path)
-		if err != nil {
-			return fmt.Errorf("resolve abs path %q: %w", path, err)
-		}
+	sm.mu.Lock()
+	sm.setRoots(roots)
+	sm.mu.Unlock()
+	return nil
+}
 
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
-			return fmt.Errorf("relativize %q: %w", path, err)
+// setRoots installs roots and rebuilds the combined Short and Full indexes.
+// Callers hold sm.mu.
+func (sm *SearchMap) setRoots(roots []*searchRoot) {
+	short := make(map[string][]string)
+	full := make(map[string]string)
+	for _, r := range roots {
+		for key, p := range r.full {
+			if _, ok := full[key]; !ok {
+				full[key] = p
+			}
+		}
+		for key, list := range r.short {
+			short[key] = append(short[key], list...)
 		}
+	}
+	sm.roots, sm.Short, sm.Full = roots, short, full
+	sm.invalidate()
+}
+
+// Roots returns the configured search roots, highest precedence first.
+func (sm *SearchMap) Roots() []string {
+	out := make([]string, len(sm.layers))
+	for i, l := range sm.layers {
+		out[i] = l.path
+	}
+	return out
+}
+
+// Keys returns all full keys in sorted order.
+func (sm *SearchMap) Keys() []string {
+	sm.mu.RLock()
+	keys := make([]string, 0, len(sm.Full))
+	for k := range sm.Full {
+		keys = append(keys, k)
+	}
+	sm.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// SetWorkDirBase sets the directory that relative work dirs of configs on
+// virtual filesystems (FromFS, LayeredFS) resolve against: the root of such a
+// filesystem maps onto dir. It defaults to the current directory.
+func (sm *SearchMap) SetWorkDirBase(dir string) {
+	sm.mu.Lock()
+	sm.workDirBase = dir
+	sm.invalidate()
+	sm.mu.Unlock()
+}
+
+// WorkDirBase returns the base set with SetWorkDirBase.
+func (sm *SearchMap) WorkDirBase() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.workDirBase
+}
+
+// ReadSource reads a resolved config file from its filesystem.
+func (sm *SearchMap) ReadSource(src ConfigSource) ([]byte, error) {
+	if src.layer < 0 || src.layer >= len(sm.layers) {
+		return nil, fmt.Errorf("read %s: unknown search root", src.Path)
+	}
+	return sm.layers[src.layer].fs.ReadFile(src.Path)
+}
+
+func indexRoot(layer int, l searchLayer, opts IndexOptions) (*searchRoot, error) {
+	r := &searchRoot{
+		layer: layer,
+		path:  l.path,
+		short: make(map[string][]string),
+		full:  make(map[string]string),
+	}
+
+	missing, err := l.walkConfigs(opts, func(cfgPath, rel string, d fs.DirEntry) error {
 		relKey := strings.TrimSuffix(rel, ".json")
-		sm.Full[relKey] = absPath
+		r.full[relKey] = cfgPath
 
 		shortKey := strings.TrimSuffix(d.Name(), ".json")
-		sm.Short[shortKey] = append(sm.Short[shortKey], absPath)
+		r.short[shortKey] = append(r.short[shortKey], cfgPath)
 		return nil
 	})
 	if err != nil {
-		return sm, err
+		return nil, err
 	}
-	return sm, nil
+	if missing {
+		return nil, nil
+	}
+	return r, nil
 }
 
-// Thin wrapper using osFS.
-func NewSearchMap(root string) (*SearchMap, error) {
-	return NewSearchMapWithFS(root, osFS{})
+// relPath returns the path a walked file is indexed under and its path
+// relative to the root.
+func (l searchLayer) relPath(p string, local bool) (cfgPath, rel string, err error) {
+	if !local {
+		root := cleanVirtual(l.path)
+		p = cleanVirtual(p)
+		if root == "." {
+			return p, p, nil
+		}
+		return p, strings.TrimPrefix(p, root+"/"), nil
+	}
+
+	absPath, err := filepath.Abs(p)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve abs path %q: %w", p, err)
+	}
+	rel, err = filepath.Rel(l.path, p)
+	if err != nil {
+		return "", "", fmt.Errorf("relativize %q: %w", p, err)
+	}
+	return absPath, rel, nil
 }
 
-// Resolve finds the one absolute path for name.
-// name can be either the short key ("dev") or full key ("env/dev").
-func (sm *SearchMap) Resolve(name string) (string, error) {
+// keyFor returns the path of p relative to this root, if p is inside it.
+func (l searchLayer) keyFor(p string) (string, bool) {
+	if !isLocalFS(l.fs) {
+		root, p := cleanVirtual(l.path), cleanVirtual(p)
+		if root == "." {
+			return p, true
+		}
+		rel, ok := strings.CutPrefix(p, root+"/")
+		return rel, ok
+	}
+
+	absRoot, err := filepath.Abs(l.path)
+	if err != nil {
+		return "", false
+	}
+	if p, err = filepath.Abs(p); err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return rel, true
+}
+
+// isLocal reports whether every root is on the OS filesystem.
+func (sm *SearchMap) isLocal() bool {
+	for _, l := range sm.layers {
+		if !isLocalFS(l.fs) {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanVirtual cleans a path on a virtual filesystem the way io/fs expects it.
+func cleanVirtual(p string) string {
+	p = strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// resolve finds the one source for name within this root.
+func (r *searchRoot) resolve(name string) (ConfigSource, error) {
 	// Try full-key first
-	if p, ok := sm.Full[name]; ok {
-		return p, nil
+	if p, ok := r.full[name]; ok {
+		return ConfigSource{Root: r.path, Key: name, Path: p, layer: r.layer}, nil
 	}
 
 	// Then short-key
-	list, ok := sm.Short[name]
+	list, ok := r.short[name]
 	if !ok || len(list) == 0 {
-		return "", os.ErrNotExist
+		return ConfigSource{}, os.ErrNotExist
 	}
 	if len(list) > 1 {
-		return "", fmt.Errorf(
-			"ambiguous config %q matches:\n  - %s",
-			name, strings.Join(list, "\n  - "),
+		return ConfigSource{}, fmt.Errorf(
+			"ambiguous config %q in %s matches:\n  - %s",
+			name, r.path, strings.Join(list, "\n  - "),
 		)
 	}
-	return list[0], nil
+	for key, p := range r.full {
+		if p == list[0] {
+			return ConfigSource{Root: r.path, Key: key, Path: p, layer: r.layer}, nil
+		}
+	}
+	return ConfigSource{Root: r.path, Key: name, Path: list[0], layer: r.layer}, nil
+}
+
+// ResolveSources finds the config file for name in every root, ordered by
+// precedence (highest first). Ambiguous short keys are only reported when
+// they occur within a single root.
+func (sm *SearchMap) ResolveSources(name string) ([]ConfigSource, error) {
+	sm.mu.RLock()
+	roots := sm.roots
+	sm.mu.RUnlock()
+
+	var out []ConfigSource
+	for _, r := range roots {
+		src, err := r.resolve(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, src)
+	}
+	if len(out) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return out, nil
+}
+
+// Resolve finds the highest precedence path for name.
+// name can be either the short key ("dev") or full key ("env/dev").
+func (sm *SearchMap) Resolve(name string) (string, error) {
+	srcs, err := sm.ResolveSources(name)
+	if err != nil {
+		return "", err
+	}
+	return srcs[0].Path, nil
 }
 
 // Load locates, reads, unmarshals and post-processes a MetaHeader.
-// Should ensure MetaHeader.Name is set.
+// When name is found in several roots, the files are merged with higher
+// precedence roots overriding lower ones.
+// Should ensure MetaHeader.Name is set. $ref references in the spec are
+// replaced with the values they point to.
 func (sm *SearchMap) Load(name string, verbose bool) (*MetaHeader, error) {
-	cfgPath, err := sm.Resolve(name)
+	return sm.load(name, verbose, nil)
+}
+
+// load is Load with the files being loaded through $ref, for cycle detection.
+func (sm *SearchMap) load(name string, verbose bool, stack []string) (*MetaHeader, error) {
+	srcs, err := sm.ResolveSources(name)
 	if err != nil {
 		return nil, err
 	}
+	if slices.Contains(stack, srcs[0].Path) {
+		return nil, refCycle(stack, srcs[0].Path)
+	}
 
-	if verbose {
-		Log().Debugf("reading %s config: %s\n", name, cfgPath)
+	// Read every source first: the migrations that apply depend on the
+	// adapter, which any of them may set.
+	datas := make([][]byte, len(srcs))
+	var adapterID, metaName string
+	for i, src := range srcs {
+		if verbose {
+			Log().Debugf("reading %s config: %s\n", name, src.Path)
+		}
+		data, err := sm.ReadSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", src.Path, err)
+		}
+		var id struct {
+			Adapter string `json:"adapter"`
+			Name    string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &id); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", src.Path, err)
+		}
+		if adapterID == "" {
+			adapterID = id.Adapter
+		}
+		if metaName == "" {
+			metaName = strings.TrimSpace(id.Name)
+		}
+		datas[i] = data
+	}
+	if adapterID == "" {
+		adapterID = metaName
+	}
+	if adapterID == "" {
+		adapterID = strings.TrimSuffix(path.Base(filepath.ToSlash(srcs[0].Path)), ".json")
 	}
 
-	data, err := sm.fs.ReadFile(cfgPath)
-	if err != nil {
-		return nil, fmt.Errorf("read %s: %w", cfgPath, err)
+	var (
+		merged  []byte
+		workDir string
+		version string // api_version the sources merged so far were written in
+	)
+	for i := len(srcs) - 1; i >= 0; i-- {
+		cfgPath := srcs[i].Path
+		data := datas[i]
+
+		// work_dir is relative to the file that sets it.
+		var wd struct {
+			WorkDir string `json:"work_dir"`
+		}
+		if err := json.Unmarshal(data, &wd); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", cfgPath, err)
+		}
+		if wd.WorkDir != "" {
+			workDir, err = sm.resolveWorkDir(srcs[i], wd.WorkDir)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Each source is migrated from its own api_version before merging.
+		data, version, err = sm.migrateSource(adapterID, srcs[i], data, version, i == len(srcs)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		merged, err = mergeJSON(merged, data)
+		if err != nil {
+			return nil, fmt.Errorf("merge %s: %w", cfgPath, err)
+		}
 	}
 
 	var h MetaHeader
-	if err := json.Unmarshal(data, &h); err != nil {
-		return nil, fmt.Errorf("decode %s: %w", cfgPath, err)
+	if err := json.Unmarshal(merged, &h); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", srcs[0].Path, err)
 	}
+	h.WorkDir = workDir
+	h.Sources = srcs
 
 	if strings.TrimSpace(h.Name) == "" {
-		h.Name = strings.TrimSuffix(filepath.Base(cfgPath), ".json")
+		h.Name = strings.TrimSuffix(path.Base(filepath.ToSlash(srcs[0].Path)), ".json")
 	}
 
 	// Override from env/contextMap if present
 	if envWorkDir, ok := workDirMap[h.Name]; ok {
-		h.WorkDir = filepath.Clean(envWorkDir)
-	}
-
-	// Make Context absolute if it’s relative
-	if h.WorkDir != "" && !filepath.IsAbs(h.WorkDir) {
-		dir := filepath.Dir(cfgPath)
-		absWorkDir, err := filepath.Abs(filepath.Join(dir, h.WorkDir))
+		h.WorkDir, err = sm.resolveWorkDir(srcs[0], filepath.Clean(envWorkDir))
 		if err != nil {
-			return nil, fmt.Errorf("resolve context %q: %w", h.WorkDir, err)
+			return nil, err
 		}
-		h.WorkDir = filepath.Clean(absWorkDir)
+	}
+
+	if err := sm.resolveSpecRefs(&h, append(slices.Clone(stack), srcs[0].Path)); err != nil {
+		return nil, err
+	}
+	if err := h.snapshot(); err != nil {
+		return nil, err
 	}
 
 	return &h, nil
 }
 
+// resolveWorkDir makes a config file's work dir absolute. On the OS filesystem
+// a relative work dir is relative to the file's directory; on other
+// filesystems the root maps onto the work dir base. The result is translated
+// to the host with the PathMapper.
+func (sm *SearchMap) resolveWorkDir(src ConfigSource, workDir string) (string, error) {
+	if filepath.IsAbs(workDir) {
+		return sm.PathMapper().ToHost(workDir), nil
+	}
+
+	var target string
+	if src.layer < len(sm.layers) && !isLocalFS(sm.layers[src.layer].fs) {
+		rel := path.Join(path.Dir(filepath.ToSlash(src.Key)), filepath.ToSlash(workDir))
+		target = filepath.Join(sm.WorkDirBase(), filepath.FromSlash(rel))
+	} else {
+		target = filepath.Join(filepath.Dir(src.Path), workDir)
+	}
+
+	absWorkDir, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("resolve context %q: %w", workDir, err)
+	}
+	return sm.PathMapper().ToHost(absWorkDir), nil
+}
+
 // LoadAll walks through every indexed config, loads it, and
 // returns those whose Adapter matches adapterID (or all if adapterID=="").
+// Unlike Query it reads the files every time, so it sees edits made since
+// the last index.
 func (sm *SearchMap) LoadAll(adapterID string) ([]*MetaHeader, error) {
-	// Collect keys in deterministic order
-	keys := make([]string, 0, len(sm.Full))
-	for k := range sm.Full {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
+	keys := sm.Keys()
 
 	var result []*MetaHeader
 	for _, key := range keys {
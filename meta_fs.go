@@ -1,9 +1,15 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 )
 
 type FileSystem interface {
@@ -20,3 +26,245 @@ func (osFS) WalkDir(root string, fn fs.WalkDirFunc) error {
 func (osFS) ReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
+
+// WritableFileSystem is a FileSystem that SearchMap.Save and Delete can write
+// to. WriteFile must replace the file atomically and create missing parent
+// directories. Implementing Stat(name) (fs.FileInfo, error) as well lets
+// rewrites keep a file's permissions.
+type WritableFileSystem interface {
+	FileSystem
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// WriteFile writes to a temp file in the same directory and renames it over
+// name, so readers never see a partial file.
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// fileMode returns the permissions of the existing file name on fsys, so
+// rewrites keep them, or def for new files and filesystems that can't stat.
+func fileMode(fsys FileSystem, name string, def fs.FileMode) fs.FileMode {
+	st, ok := fsys.(interface {
+		Stat(name string) (fs.FileInfo, error)
+	})
+	if !ok {
+		return def
+	}
+	info, err := st.Stat(name)
+	if err != nil {
+		return def
+	}
+	return info.Mode().Perm()
+}
+
+// virtualFS is implemented by the filesystems FromFS and LayeredFS, whose
+// paths are slash-separated and relative to their own root.
+type virtualFS interface {
+	virtual()
+}
+
+// isLocalFS reports whether paths on fsys are OS paths that can be made
+// absolute. Only filesystems marked virtual are not; any other FileSystem is
+// assumed to be backed by the OS.
+func isLocalFS(fsys FileSystem) bool {
+	_, ok := fsys.(virtualFS)
+	return !ok
+}
+
+// ioFS adapts an io/fs.FS (such as embed.FS) to FileSystem.
+type ioFS struct {
+	fsys fs.FS
+}
+
+// FromFS adapts any io/fs.FS, such as an embed.FS, to a FileSystem. Paths are
+// slash-separated and relative to the root of fsys.
+func FromFS(fsys fs.FS) FileSystem {
+	return ioFS{fsys: fsys}
+}
+
+func (ioFS) virtual() {}
+
+func (f ioFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(f.fsys, root, fn)
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+// OSFS returns the FileSystem for the OS filesystem.
+func OSFS() FileSystem {
+	return osFS{}
+}
+
+// Layer is a config tree at Root on FS.
+type Layer struct {
+	FS   FileSystem
+	Root string
+}
+
+// join returns name inside the layer's root.
+func (l Layer) join(name string) string {
+	if isLocalFS(l.FS) {
+		return filepath.Join(l.Root, filepath.FromSlash(name))
+	}
+	return cleanVirtual(path.Join(l.Root, filepath.ToSlash(name)))
+}
+
+// LayeredFS stacks config trees, highest precedence first, e.g. on-disk
+// overrides over embedded defaults:
+//
+//	//go:embed defaults
+//	var defaults embed.FS
+//
+//	sm, err := core.NewLayeredSearchMap(
+//		core.Layer{FS: core.OSFS(), Root: ".core"},
+//		core.Layer{FS: core.FromFS(defaults), Root: "defaults"},
+//	)
+//
+// A file present in several layers is a single file whose JSON is merged,
+// higher layers over lower ones. A SearchMap given a LayeredFS indexes each
+// layer as its own root, so sources and work dirs stay per file.
+type LayeredFS []Layer
+
+func (LayeredFS) virtual() {}
+
+// WalkDir walks the union of all layers. Paths are slash-separated and
+// relative to the LayeredFS.
+func (l LayeredFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	root = cleanVirtual(root)
+	entries := make(map[string]fs.DirEntry)
+	for _, layer := range l {
+		lroot := layer.join(root)
+		err := layer.FS.WalkDir(lroot, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == lroot && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipDir
+				}
+				return err
+			}
+			rel, err := layerRel(layer, lroot, p)
+			if err != nil {
+				return err
+			}
+			if _, ok := entries[rel]; !ok {
+				entries[rel] = d
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		return fn(root, nil, &fs.PathError{Op: "walk", Path: root, Err: fs.ErrNotExist})
+	}
+
+	rels := make([]string, 0, len(entries))
+	for rel := range entries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var skip []string // directories whose remaining entries are skipped
+	for _, rel := range rels {
+		if slices.ContainsFunc(skip, func(dir string) bool { return dir == "." || strings.HasPrefix(rel, dir+"/") }) {
+			continue
+		}
+		d := entries[rel]
+		err := fn(cleanVirtual(path.Join(root, rel)), d, nil)
+		switch {
+		case errors.Is(err, fs.SkipAll):
+			return nil
+		case errors.Is(err, fs.SkipDir) && d.IsDir():
+			if rel == "." {
+				return nil
+			}
+			skip = append(skip, rel)
+		case errors.Is(err, fs.SkipDir):
+			skip = append(skip, path.Dir(rel))
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// layerRel returns p relative to the layer root as a slash path.
+func layerRel(layer Layer, lroot, p string) (string, error) {
+	if !isLocalFS(layer.FS) {
+		if p == lroot {
+			return ".", nil
+		}
+		return strings.TrimPrefix(p, strings.TrimSuffix(lroot, "/")+"/"), nil
+	}
+	rel, err := filepath.Rel(lroot, p)
+	if err != nil {
+		return "", fmt.Errorf("relativize %q: %w", p, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ReadFile reads name from every layer that has it. JSON files are merged,
+// higher layers over lower ones; other files come from the highest layer.
+func (l LayeredFS) ReadFile(name string) ([]byte, error) {
+	var merged []byte
+	found := false
+	for i := len(l) - 1; i >= 0; i-- {
+		data, err := l[i].FS.ReadFile(l[i].join(name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !found || path.Ext(name) != ".json" {
+			merged, found = data, true
+			continue
+		}
+		if merged, err = mergeJSON(merged, data); err != nil {
+			return nil, fmt.Errorf("merge %s: %w", l[i].join(name), err)
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return merged, nil
+}
@@ -0,0 +1,438 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+var runSeq atomic.Int64
+
+// runID returns id with a suffix unique to this run, so adapters the default
+// registry cached in an earlier run (go test -count=N) aren't reused.
+func runID(id string) string {
+	return fmt.Sprintf("%s-%d", id, runSeq.Add(1))
+}
+
+func TestSearchMap_MultipleRoots(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	system := filepath.Join(dir, "system")
+
+	writeFile(t, filepath.Join(system, "svc.json"),
+		`{"adapter":"svc","work_dir":"sys-wd","spec":{"a":"system","b":"system"}}`)
+	writeFile(t, filepath.Join(project, "svc.json"),
+		`{"adapter":"svc","spec":{"a":"project"}}`)
+
+	// The same short key twice within one root is ambiguous, across roots it is not.
+	writeFile(t, filepath.Join(system, "x", "dup.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(project, "dup.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(project, "y", "amb.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(project, "z", "amb.json"), `{"adapter":"svc"}`)
+
+	sm, err := core.NewSearchMap(project, filepath.Join(dir, "missing"), system)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load(svc): %v", err)
+	}
+	if got, want := string(meta.RawSpec), `{"a":"project","b":"system"}`; got != want {
+		t.Fatalf("RawSpec = %s, want %s", got, want)
+	}
+	if got, want := meta.WorkDir, filepath.Join(system, "sys-wd"); got != want {
+		t.Fatalf("WorkDir = %q, want %q", got, want)
+	}
+	if len(meta.Sources) != 2 || meta.Sources[0].Root != project || meta.Sources[1].Root != system {
+		t.Fatalf("Sources = %+v, want project then system", meta.Sources)
+	}
+
+	srcs, err := sm.ResolveSources("dup")
+	if err != nil {
+		t.Fatalf("ResolveSources(dup): %v", err)
+	}
+	if len(srcs) != 2 || srcs[1].Key != filepath.Join("x", "dup") {
+		t.Fatalf("ResolveSources(dup) = %+v", srcs)
+	}
+
+	if _, err := sm.Resolve("amb"); err == nil {
+		t.Fatalf("Resolve(amb): expected ambiguity error")
+	}
+}
+
+func TestSearchMap_RootErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := core.NewSearchMap(filepath.Join(dir, "typo")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("NewSearchMap(missing) error = %v, want fs.ErrNotExist", err)
+	}
+
+	t.Setenv("HOME", dir)
+	writeFile(t, filepath.Join(dir, ".config", "core", "svc.json"), `{"adapter":"svc"}`)
+	sm, err := core.NewSearchMap("~/.config/core")
+	if err != nil {
+		t.Fatalf("NewSearchMap(~): %v", err)
+	}
+	if got, want := sm.Roots(), []string{filepath.Join(dir, ".config", "core")}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("Roots() = %v, want %v", got, want)
+	}
+	if _, err := sm.Load("svc", false); err != nil {
+		t.Fatalf("Load(svc): %v", err)
+	}
+}
+
+func TestSearchMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/svc.json":     {Data: []byte(`{"adapter":"svc","work_dir":".","spec":{"a":"embedded"}}`)},
+		"defaults/env/dev.json": {Data: []byte(`{"adapter":"svc","work_dir":"build"}`)},
+		"other/ignored.json":    {Data: []byte(`{"adapter":"svc"}`)},
+	}
+
+	sm, err := core.NewSearchMapFS(fsys, "defaults")
+	if err != nil {
+		t.Fatalf("NewSearchMapFS: %v", err)
+	}
+	base := t.TempDir()
+	sm.SetWorkDirBase(base)
+
+	if got, want := sm.Keys(), []string{"env/dev", "svc"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load(svc): %v", err)
+	}
+	if got, want := meta.Sources[0].Path, "defaults/svc.json"; got != want {
+		t.Fatalf("Path = %q, want virtual path %q", got, want)
+	}
+	if got := meta.WorkDir; got != base {
+		t.Fatalf("WorkDir = %q, want %q", got, base)
+	}
+
+	meta, err = sm.Load("dev", false)
+	if err != nil {
+		t.Fatalf("Load(dev): %v", err)
+	}
+	if got, want := meta.WorkDir, filepath.Join(base, "env", "build"); got != want {
+		t.Fatalf("WorkDir = %q, want %q", got, want)
+	}
+}
+
+// countingFS is a caller-supplied FileSystem backed by the OS.
+type countingFS struct {
+	reads *int
+}
+
+func (f countingFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+func (f countingFS) ReadFile(name string) ([]byte, error) {
+	*f.reads++
+	return os.ReadFile(name)
+}
+
+func TestSearchMapWithFS_CustomOSBacked(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "x.json"), `{"adapter":"svc","work_dir":"wd"}`)
+
+	reads := 0
+	sm, err := core.NewSearchMapWithFS(dir, countingFS{&reads})
+	if err != nil {
+		t.Fatalf("NewSearchMapWithFS: %v", err)
+	}
+	sm.SetWorkDirBase(t.TempDir())
+
+	meta, err := sm.Load("x", false)
+	if err != nil {
+		t.Fatalf("Load(x): %v", err)
+	}
+	if got, want := meta.Sources[0].Path, filepath.Join(dir, "x.json"); got != want {
+		t.Fatalf("Path = %q, want absolute OS path %q", got, want)
+	}
+	if got, want := meta.WorkDir, filepath.Join(dir, "wd"); got != want {
+		t.Fatalf("WorkDir = %q, want %q relative to the config", got, want)
+	}
+	if reads == 0 {
+		t.Fatalf("config was not read through the supplied FileSystem")
+	}
+}
+
+func TestLayeredSearchMap(t *testing.T) {
+	defaults := fstest.MapFS{
+		"defaults/adp.json":      {Data: []byte(`{"adapter":"adp","spec":{"host":"localhost","port":80}}`)},
+		"defaults/env/base.json": {Data: []byte(`{"adapter":"adp"}`)},
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "adp.json"), `{"spec":{"port":8080}}`)
+	writeFile(t, filepath.Join(dir, "local.json"), `{"adapter":"adp"}`)
+
+	layers := []core.Layer{
+		{FS: core.OSFS(), Root: dir},
+		{FS: core.FromFS(defaults), Root: "defaults"},
+	}
+	sm, err := core.NewLayeredSearchMap(layers...)
+	if err != nil {
+		t.Fatalf("NewLayeredSearchMap: %v", err)
+	}
+
+	meta, err := sm.Load("adp", false)
+	if err != nil {
+		t.Fatalf("Load(adp): %v", err)
+	}
+	if got, want := string(meta.RawSpec), `{"host":"localhost","port":8080}`; got != want {
+		t.Fatalf("RawSpec = %s, want %s", got, want)
+	}
+	if len(meta.Sources) != 2 || meta.Sources[0].Path != filepath.Join(dir, "adp.json") || meta.Sources[1].Path != "defaults/adp.json" {
+		t.Fatalf("Sources = %+v, want on-disk then embedded", meta.Sources)
+	}
+	if got, want := sm.Keys(), []string{"adp", "env/base", "local"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+
+	// Used as a plain FileSystem, the layers read as one merged tree.
+	fsys := core.LayeredFS(layers)
+	data, err := fsys.ReadFile("adp.json")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if got, want := string(data), `{"adapter":"adp","spec":{"host":"localhost","port":8080}}`; got != want {
+		t.Fatalf("ReadFile = %s, want %s", got, want)
+	}
+	var walked []string
+	err = fsys.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+	if got, want := strings.Join(walked, ","), "adp.json,env/base.json,local.json"; got != want {
+		t.Fatalf("WalkDir = %s, want %s", got, want)
+	}
+}
+
+func TestSearchMap_IgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".coreignore"), "# vendored\nnode_modules/\n/fixtures/*\n!fixtures/keep.json\n")
+	writeFile(t, filepath.Join(dir, "svc.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(dir, "package.json"), `{"name":"web"}`)
+	writeFile(t, filepath.Join(dir, "node_modules", "x", "svc.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(dir, "fixtures", "drop.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(dir, "fixtures", "keep.json"), `{"adapter":"svc"}`)
+	writeFile(t, filepath.Join(dir, "tmp", "scratch.json"), `{"adapter":"svc"}`)
+
+	sm, err := core.NewSearchMap(dir)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+	if got, want := strings.Join(sm.Keys(), ","), "fixtures/keep,package,svc,tmp/scratch"; got != want {
+		t.Fatalf("Keys() = %s, want %s", got, want)
+	}
+	if _, err := sm.Resolve("svc"); err != nil {
+		t.Fatalf("Resolve(svc): %v", err)
+	}
+
+	if err := sm.SetIndexOptions(core.IndexOptions{Exclude: []string{"tmp/"}, RequireAdapter: true}); err != nil {
+		t.Fatalf("SetIndexOptions: %v", err)
+	}
+	if got, want := strings.Join(sm.Keys(), ","), "fixtures/keep,svc"; got != want {
+		t.Fatalf("Keys() = %s, want %s", got, want)
+	}
+
+	if err := sm.SetIndexOptions(core.IndexOptions{Include: []string{"fixtures/**"}}); err != nil {
+		t.Fatalf("SetIndexOptions: %v", err)
+	}
+	if got, want := strings.Join(sm.Keys(), ","), "fixtures/keep"; got != want {
+		t.Fatalf("Keys() = %s, want %s", got, want)
+	}
+}
+
+func TestSearchMap_SaveDelete(t *testing.T) {
+	dir := t.TempDir()
+	svc := filepath.Join(dir, "svc.json")
+	writeFile(t, svc, "{\n    \"spec\": { \"b\": 1, \"a\": 2 },\n    \"adapter\": \"svc\",\n    \"work_dir\": \"src\"\n}\n")
+
+	sm, err := core.NewSearchMap(dir)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+
+	// Unchanged values keep their formatting, changed ones are re-indented in place.
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	meta.Adapter = "other"
+	if err := sm.Save(meta, "svc"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, _ := os.ReadFile(svc)
+	want := "{\n    \"spec\": { \"b\": 1, \"a\": 2 },\n    \"adapter\": \"other\",\n    \"work_dir\": \"src\"\n}\n"
+	if string(data) != want {
+		t.Fatalf("saved file =\n%s\nwant\n%s", data, want)
+	}
+
+	// New files are indexed right away, with the work dir made relative.
+	item := &core.MetaHeader{
+		Name:    "dev",
+		Adapter: "svc",
+		RawSpec: []byte(`{"x":true}`),
+		WorkDir: filepath.Join(dir, "env", "build"),
+	}
+	if err := sm.Save(item, "env/dev"); err != nil {
+		t.Fatalf("Save(env/dev): %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "env", "dev.json"))
+	want = "{\n  \"adapter\": \"svc\",\n  \"spec\": {\n    \"x\": true\n  },\n  \"work_dir\": \"build\"\n}\n"
+	if string(data) != want {
+		t.Fatalf("new file =\n%s\nwant\n%s", data, want)
+	}
+	loaded, err := sm.Load("dev", false)
+	if err != nil {
+		t.Fatalf("Load(dev): %v", err)
+	}
+	if loaded.WorkDir != item.WorkDir {
+		t.Fatalf("WorkDir = %q, want %q", loaded.WorkDir, item.WorkDir)
+	}
+
+	if err := sm.Delete("env/dev"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "env", "dev.json")); !os.IsNotExist(err) {
+		t.Fatalf("file still exists: %v", err)
+	}
+	if _, err := sm.Resolve("dev"); err == nil {
+		t.Fatalf("Resolve(dev) after Delete: expected error")
+	}
+	if err := sm.Delete("env/dev"); err == nil {
+		t.Fatalf("second Delete: expected error")
+	}
+}
+
+func TestSearchMap_SaveOnlyChanges(t *testing.T) {
+	dir := t.TempDir()
+	project, system := filepath.Join(dir, "project"), filepath.Join(dir, "system")
+	writeFile(t, filepath.Join(system, "db.json"), `{"adapter":"svc","spec":{"host":"db.internal"}}`)
+	writeFile(t, filepath.Join(system, "svc.json"),
+		`{"adapter":"svc","labels":{"tier":"backend"},"spec":{"a":1,"host":"system"}}`)
+	writeFile(t, filepath.Join(project, "svc.json"), `{"spec":{"host":{"$ref":"db#/spec/host"}}}`)
+
+	sm, err := core.NewSearchMap(project, system)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	meta.Labels["env"] = "dev"
+	if err := sm.Save(meta, "svc"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Lower-root values and the reference stay where they are.
+	data, _ := os.ReadFile(filepath.Join(project, "svc.json"))
+	if got, want := string(data), `{"spec":{"host":{"$ref":"db#/spec/host"}},"labels":{"env":"dev"}}`+"\n"; got != want {
+		t.Fatalf("saved file = %s, want %s", got, want)
+	}
+	if meta, err = sm.Load("svc", false); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := meta.Labels; got["env"] != "dev" || got["tier"] != "backend" {
+		t.Fatalf("Labels = %v, want env and tier", got)
+	}
+}
+
+func TestSearchMap_SaveAsOtherKey(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "svc.json"), `{"adapter":"svc","labels":{"tier":"backend"},"spec":{"host":"db","port":5432}}`)
+
+	sm, err := core.NewSearchMap(dir)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	meta.Labels["env"] = "dev"
+	if err := sm.Save(meta, "copies/svc"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	copied, err := sm.Load("copies/svc", false)
+	if err != nil {
+		t.Fatalf("Load copy: %v", err)
+	}
+	if copied.Adapter != "svc" || copied.Labels["tier"] != "backend" || copied.Labels["env"] != "dev" {
+		t.Fatalf("copy = %+v, want the whole header", copied)
+	}
+	var spec bytes.Buffer
+	if err := json.Compact(&spec, copied.RawSpec); err != nil {
+		t.Fatalf("compact spec: %v", err)
+	}
+	if got, want := spec.String(), `{"host":"db","port":5432}`; got != want {
+		t.Fatalf("copy spec = %s, want %s", got, want)
+	}
+}
+
+func TestSearchMap_SaveKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions")
+	}
+	dir := t.TempDir()
+	svc := filepath.Join(dir, "svc.json")
+	writeFile(t, svc, `{"adapter":"svc","spec":{"password":"x"}}`)
+	if err := os.Chmod(svc, 0o600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	sm, err := core.NewSearchMap(dir)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+	meta, err := sm.Load("svc", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	meta.Adapter = "other"
+	if err := sm.Save(meta, "svc"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	info, err := os.Stat(svc)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o600 {
+		t.Fatalf("mode = %v, want 0600", got)
+	}
+}
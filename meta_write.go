@@ -0,0 +1,538 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Save writes meta to relKey (a full key, without .json) in the highest
+// precedence writable root and updates the index. An existing file keeps its
+// key order and formatting; only changed values are rewritten. An absolute
+// work dir is written relative to the file again.
+//
+// A header returned by Load holds the merged spec of every root with $ref
+// values resolved. Saving it writes only the values changed since it was
+// loaded, by JSON pointer, so values inherited from lower roots and
+// references stay where they are. Other headers, and loaded ones saved under
+// another key, are written whole.
+func (sm *SearchMap) Save(meta *MetaHeader, relKey string) error {
+	t, err := sm.saveTarget(relKey)
+	if err != nil {
+		return err
+	}
+	cfgPath, key := t.path, t.key
+
+	old, err := t.fs.ReadFile(cfgPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", cfgPath, err)
+	}
+
+	src := ConfigSource{Root: sm.layers[t.layer].path, Key: key, Path: cfgPath, layer: t.layer}
+	var fields []objectField
+	if meta.loaded != nil && sm.sameConfig(meta, key) {
+		fields, err = sm.changedFields(meta, src, old)
+	} else {
+		fields, err = sm.metaFields(meta, src, old)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := patchObject(old, fields)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", cfgPath, err)
+	}
+	if err := t.fs.WriteFile(cfgPath, data, fileMode(t.fs, cfgPath, 0o644)); err != nil {
+		return fmt.Errorf("write %s: %w", cfgPath, err)
+	}
+	if old == nil {
+		Log().Debugf("created %s\n", cfgPath)
+	}
+
+	return sm.updateRoot(t.layer, func(r *searchRoot) {
+		if _, ok := r.full[key]; ok {
+			return
+		}
+		r.full[key] = cfgPath
+		short := path.Base(filepath.ToSlash(key))
+		r.short[short] = append(r.short[short], cfgPath)
+	})
+}
+
+// sameConfig reports whether key names the config meta was loaded from.
+func (sm *SearchMap) sameConfig(meta *MetaHeader, key string) bool {
+	return len(meta.Sources) > 0 && filepath.ToSlash(meta.Sources[0].Key) == filepath.ToSlash(key)
+}
+
+// Delete removes the config file for relKey (a full key) from the highest
+// precedence writable root that has it and updates the index.
+func (sm *SearchMap) Delete(relKey string) error {
+	relKey = cleanKey(relKey)
+
+	sm.mu.RLock()
+	roots := sm.roots
+	sm.mu.RUnlock()
+
+	for _, r := range roots {
+		key := sm.layers[r.layer].indexKey(relKey)
+		cfgPath, ok := r.full[key]
+		if !ok {
+			continue
+		}
+		wfs, ok := sm.layers[r.layer].fs.(WritableFileSystem)
+		if !ok {
+			continue
+		}
+		if err := wfs.Remove(cfgPath); err != nil {
+			return fmt.Errorf("delete %s: %w", cfgPath, err)
+		}
+		return sm.updateRoot(r.layer, func(r *searchRoot) {
+			delete(r.full, key)
+			short := path.Base(filepath.ToSlash(relKey))
+			r.short[short] = slices.DeleteFunc(r.short[short], func(p string) bool { return p == cfgPath })
+			if len(r.short[short]) == 0 {
+				delete(r.short, short)
+			}
+		})
+	}
+	return fmt.Errorf("delete %s: %w", relKey, os.ErrNotExist)
+}
+
+// saveTarget is where Save writes a key.
+type saveTarget struct {
+	layer int
+	fs    WritableFileSystem
+	key   string // full key as indexed in the root
+	path  string
+}
+
+// saveTarget returns the file relKey is saved to in the highest precedence
+// root that can be written to.
+func (sm *SearchMap) saveTarget(relKey string) (saveTarget, error) {
+	for i, l := range sm.layers {
+		wfs, ok := l.fs.(WritableFileSystem)
+		if !ok {
+			continue
+		}
+		relKey = cleanKey(relKey)
+		cfgPath, _, err := l.relPath(l.join(relKey+".json"), isLocalFS(l.fs))
+		if err != nil {
+			return saveTarget{}, err
+		}
+		return saveTarget{layer: i, fs: wfs, key: l.indexKey(relKey), path: cfgPath}, nil
+	}
+	return saveTarget{}, fmt.Errorf("save %s: no writable search root", relKey)
+}
+
+// updateRoot applies fn to a copy of the layer's index, so readers holding the
+// old roots are unaffected, and rebuilds the combined indexes.
+func (sm *SearchMap) updateRoot(layer int, fn func(r *searchRoot)) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	roots := make([]*searchRoot, 0, len(sm.roots)+1)
+	found := false
+	for _, r := range sm.roots {
+		if r.layer != layer {
+			roots = append(roots, r)
+			continue
+		}
+		cp := &searchRoot{
+			layer: r.layer,
+			path:  r.path,
+			short: make(map[string][]string, len(r.short)),
+			full:  make(map[string]string, len(r.full)),
+		}
+		for k, v := range r.short {
+			cp.short[k] = append([]string(nil), v...)
+		}
+		for k, v := range r.full {
+			cp.full[k] = v
+		}
+		fn(cp)
+		roots = append(roots, cp)
+		found = true
+	}
+
+	// The root didn't exist when it was indexed.
+	if !found {
+		r := &searchRoot{
+			layer: layer,
+			path:  sm.layers[layer].path,
+			short: make(map[string][]string),
+			full:  make(map[string]string),
+		}
+		fn(r)
+		roots = append(roots, r)
+		sort.SliceStable(roots, func(i, j int) bool { return roots[i].layer < roots[j].layer })
+	}
+
+	sm.setRoots(roots)
+	return nil
+}
+
+// cleanKey normalises a full key: slash-separated, without .json.
+func cleanKey(relKey string) string {
+	return strings.TrimSuffix(cleanVirtual(relKey), ".json")
+}
+
+// indexKey returns the key a slash-separated full key is indexed under in
+// this root; keys on the OS filesystem use the OS separator.
+func (l searchLayer) indexKey(relKey string) string {
+	if isLocalFS(l.fs) {
+		return filepath.FromSlash(relKey)
+	}
+	return relKey
+}
+
+// objectField is a top-level key of a config file. A nil value removes it.
+type objectField struct {
+	key   string
+	value json.RawMessage
+}
+
+// metaFields returns the fields of meta as they should be written to src.
+func (sm *SearchMap) metaFields(meta *MetaHeader, src ConfigSource, old []byte) ([]objectField, error) {
+	var existing map[string]json.RawMessage
+	if old != nil {
+		if err := json.Unmarshal(old, &existing); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", src.Path, err)
+		}
+	}
+	has := func(key string) bool {
+		for k := range existing {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var fields []objectField
+	set := func(key string, v any, keep bool) error {
+		if !keep {
+			fields = append(fields, objectField{key: key})
+			return nil
+		}
+		raw, ok := v.(json.RawMessage)
+		if !ok {
+			var err error
+			if raw, err = json.Marshal(v); err != nil {
+				return err
+			}
+		}
+		fields = append(fields, objectField{key: key, value: raw})
+		return nil
+	}
+
+	// The name falls back on the file name, so only write it when it differs.
+	base := path.Base(filepath.ToSlash(src.Key))
+	workDir, err := sm.relWorkDir(src, meta.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range []error{
+		set("name", meta.Name, meta.Name != "" && (meta.Name != base || has("name"))),
+		set("api_version", meta.APIVersion, meta.APIVersion != ""),
+		set("adapter", meta.Adapter, meta.Adapter != ""),
+		set("labels", meta.Labels, len(meta.Labels) > 0),
+		set("dependencies", meta.Dependencies, len(meta.Dependencies) > 0),
+		set("spec", meta.RawSpec, len(meta.RawSpec) > 0),
+		set("work_dir", workDir, workDir != ""),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// savedFields is the part of a header Save compares with what was loaded.
+type savedFields struct {
+	Name         string            `json:"name,omitempty"`
+	APIVersion   string            `json:"api_version,omitempty"`
+	Adapter      string            `json:"adapter,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Dependencies map[string]DepRef `json:"dependencies,omitempty"`
+	Spec         json.RawMessage   `json:"spec,omitempty"`
+}
+
+func (h *MetaHeader) savedFields() (json.RawMessage, error) {
+	return json.Marshal(savedFields{h.Name, h.APIVersion, h.Adapter, h.Labels, h.Dependencies, h.RawSpec})
+}
+
+// snapshot records the header as loaded.
+func (h *MetaHeader) snapshot() error {
+	loaded, err := h.savedFields()
+	if err != nil {
+		return fmt.Errorf("%s: %w", h.Name, err)
+	}
+	h.loaded, h.loadedWorkDir = loaded, h.WorkDir
+	return nil
+}
+
+// changedFields applies the changes made to meta since it was loaded to the
+// file at src, by JSON pointer, and returns the top-level fields they touch.
+func (sm *SearchMap) changedFields(meta *MetaHeader, src ConfigSource, old []byte) ([]objectField, error) {
+	current, err := meta.savedFields()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := DiffSpecs(meta.loaded, current)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]any{}
+	if old != nil {
+		v, err := decodeJSON(old)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", src.Path, err)
+		}
+		var ok bool
+		if doc, ok = v.(map[string]any); !ok {
+			return nil, fmt.Errorf("decode %s: config is not a JSON object", src.Path)
+		}
+	}
+
+	touched := map[string]bool{}
+	for _, c := range changes {
+		tokens := pointerTokens(c.Pointer)
+		touched[matchKey(doc, tokens[0])] = true
+		if c.Kind == ChangeRemoved {
+			removePointer(doc, tokens)
+		} else {
+			setPointer(doc, tokens, c.New)
+		}
+	}
+
+	var fields []objectField
+	for _, key := range sortedKeys(touched) {
+		v, ok := doc[key]
+		if !ok {
+			fields = append(fields, objectField{key: key})
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, objectField{key: key, value: raw})
+	}
+
+	if meta.WorkDir != meta.loadedWorkDir {
+		workDir, err := sm.relWorkDir(src, meta.WorkDir)
+		if err != nil {
+			return nil, err
+		}
+		f := objectField{key: "work_dir"}
+		if workDir != "" {
+			f.value, _ = json.Marshal(workDir)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// pointerTokens splits a JSON pointer into its unescaped tokens.
+func pointerTokens(ptr string) []string {
+	tokens := strings.Split(strings.TrimPrefix(ptr, "/"), "/")
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, t := range tokens {
+		tokens[i] = unescape.Replace(t)
+	}
+	return tokens
+}
+
+// matchKey returns the key of m that key refers to, ignoring case like
+// encoding/json does, or key itself.
+func matchKey(m map[string]any, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+// setPointer sets the value at tokens, creating missing objects on the way
+// and replacing values that are not objects.
+func setPointer(m map[string]any, tokens []string, v any) {
+	for _, tok := range tokens[:len(tokens)-1] {
+		key := matchKey(m, tok)
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			m[key] = child
+		}
+		m = child
+	}
+	m[matchKey(m, tokens[len(tokens)-1])] = v
+}
+
+// removePointer deletes the value at tokens and the objects left empty by it.
+func removePointer(m map[string]any, tokens []string) {
+	key := matchKey(m, tokens[0])
+	if len(tokens) == 1 {
+		delete(m, key)
+		return
+	}
+	child, ok := m[key].(map[string]any)
+	if !ok {
+		return
+	}
+	removePointer(child, tokens[1:])
+	if len(child) == 0 {
+		delete(m, key)
+	}
+}
+
+// relWorkDir makes an absolute work dir relative to the config file again,
+// undoing resolveWorkDir.
+func (sm *SearchMap) relWorkDir(src ConfigSource, workDir string) (string, error) {
+	if workDir == "" || !filepath.IsAbs(workDir) {
+		return filepath.ToSlash(workDir), nil
+	}
+	workDir = sm.PathMapper().ToContainer(workDir)
+
+	var dir string
+	if isLocalFS(sm.layers[src.layer].fs) {
+		dir = filepath.Dir(src.Path)
+	} else {
+		dir = filepath.Join(sm.WorkDirBase(), filepath.FromSlash(path.Dir(filepath.ToSlash(src.Key))))
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		dir = abs
+	}
+	rel, err := filepath.Rel(dir, workDir)
+	if err != nil {
+		return filepath.ToSlash(workDir), nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// patchObject applies fields to the JSON object in data. Keys keep their order
+// and unchanged values keep their formatting; new keys are appended. Without
+// data a new object is written with two-space indentation.
+func patchObject(data []byte, fields []objectField) ([]byte, error) {
+	type entry struct {
+		key     string
+		value   json.RawMessage
+		changed bool
+	}
+
+	var entries []entry
+	indent := "  "
+	if data != nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil, fmt.Errorf("config is not a JSON object")
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{key: tok.(string), value: raw})
+		}
+		indent = detectIndent(data)
+	}
+
+	for _, f := range fields {
+		i := -1
+		for j, e := range entries {
+			if strings.EqualFold(e.key, f.key) {
+				i = j
+				break
+			}
+		}
+		switch {
+		case f.value == nil && i >= 0:
+			entries = append(entries[:i], entries[i+1:]...)
+		case f.value == nil:
+		case i < 0:
+			entries = append(entries, entry{key: f.key, value: f.value, changed: true})
+		case !sameJSON(entries[i].value, f.value):
+			entries[i].value, entries[i].changed = f.value, true
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.key)
+		if indent == "" {
+			buf.Write(key)
+			buf.WriteByte(':')
+		} else {
+			buf.WriteString("\n" + indent)
+			buf.Write(key)
+			buf.WriteString(": ")
+		}
+
+		switch {
+		case !e.changed:
+			buf.Write(e.value)
+		case indent == "":
+			if err := json.Compact(&buf, e.value); err != nil {
+				return nil, err
+			}
+		default:
+			if err := json.Indent(&buf, e.value, indent, indent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if indent != "" && len(entries) > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// detectIndent returns the indentation of the first key, or "" for compact
+// objects.
+func detectIndent(data []byte) string {
+	open := bytes.IndexByte(data, '{')
+	quote := bytes.IndexByte(data, '"')
+	if open < 0 || quote < open {
+		return "  "
+	}
+	ws := data[open+1 : quote]
+	nl := bytes.LastIndexByte(ws, '\n')
+	if nl < 0 {
+		return ""
+	}
+	return string(ws[nl+1:])
+}
+
+// sameJSON reports whether a and b hold the same JSON value.
+func sameJSON(a, b json.RawMessage) bool {
+	va, errA := decodeJSON(a)
+	vb, errB := decodeJSON(b)
+	if errA != nil || errB != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
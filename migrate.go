@@ -0,0 +1,315 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MigrateFunc upgrades a spec payload from one api_version to the next. The
+// spec is empty when the config has none.
+type MigrateFunc func(spec json.RawMessage) (json.RawMessage, error)
+
+// migration is one registered upgrade step.
+type migration struct {
+	to string
+	fn MigrateFunc
+}
+
+// Migrations holds the spec migrations of adapters, keyed on api_version.
+type Migrations struct {
+	mu    sync.RWMutex
+	steps map[string]map[string]migration // adapterID -> from -> step
+}
+
+// NewMigrations returns an empty migration set.
+func NewMigrations() *Migrations {
+	return &Migrations{steps: make(map[string]map[string]migration)}
+}
+
+// Register adds the step that upgrades adapterID specs from api_version from
+// to api_version to. Steps chain: v1 -> v2 -> v3.
+func (m *Migrations) Register(adapterID, from, to string, fn MigrateFunc) {
+	adapterID = strings.ToLower(adapterID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.steps[adapterID] == nil {
+		m.steps[adapterID] = make(map[string]migration)
+	}
+	m.steps[adapterID][from] = migration{to: to, fn: fn}
+}
+
+// Latest returns the api_version a spec at version from is upgraded to, and
+// whether any migration applies.
+func (m *Migrations) Latest(adapterID, from string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	steps := m.steps[strings.ToLower(adapterID)]
+	version, seen := from, map[string]bool{from: true}
+	for {
+		step, ok := steps[version]
+		if !ok || seen[step.to] {
+			return version, version != from
+		}
+		version = step.to
+		seen[version] = true
+	}
+}
+
+// Current returns the newest api_version of adapterID: the end of its
+// migration chain, or "" without migrations. With several separate chains it
+// is the end most steps lead to, then the greatest version, so the result
+// doesn't depend on registration or map order.
+func (m *Migrations) Current(adapterID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	steps := m.steps[strings.ToLower(adapterID)]
+
+	var current string
+	best := -1
+	for _, from := range sortedKeys(steps) {
+		end, n := from, 0
+		seen := map[string]bool{from: true}
+		for {
+			step, ok := steps[end]
+			if !ok || seen[step.to] {
+				break
+			}
+			end, n = step.to, n+1
+			seen[end] = true
+		}
+		if n > best || (n == best && end > current) {
+			current, best = end, n
+		}
+	}
+	return current
+}
+
+// Apply upgrades spec from api_version from as far as the registered steps
+// go and returns the new spec and version.
+func (m *Migrations) Apply(adapterID, from string, spec json.RawMessage) (json.RawMessage, string, error) {
+	m.mu.RLock()
+	steps := m.steps[strings.ToLower(adapterID)]
+	m.mu.RUnlock()
+
+	version, seen := from, map[string]bool{from: true}
+	for {
+		step, ok := steps[version]
+		if !ok {
+			return spec, version, nil
+		}
+		if seen[step.to] {
+			return nil, "", fmt.Errorf("%s: migration cycle at api_version %q", adapterID, step.to)
+		}
+		out, err := step.fn(spec)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: migrate %q to %q: %w", adapterID, version, step.to, err)
+		}
+		spec, version = out, step.to
+		seen[version] = true
+	}
+}
+
+// SetMigrations sets the migrations Load applies. Registry.SetSearchMap
+// installs the registry's migrations.
+func (sm *SearchMap) SetMigrations(m *Migrations) {
+	sm.mu.Lock()
+	sm.migrations = m
+	sm.invalidate()
+	sm.mu.Unlock()
+}
+
+// migrateSource upgrades the spec of one source file before it is merged,
+// from the api_version it declares or, without one, the version of the
+// sources below it. It warns about outdated declared versions and returns the
+// upgraded file and the version its spec was written in, which the sources
+// above it inherit. The lowest source is migrated even without a
+// spec, so migrations can fill in specs that are left out.
+func (sm *SearchMap) migrateSource(adapterID string, src ConfigSource, data []byte, inherited string, lowest bool) ([]byte, string, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", src.Path, err)
+	}
+	from := inherited
+	raw, declared := doc["api_version"]
+	if declared {
+		if err := json.Unmarshal(raw, &from); err != nil {
+			return nil, "", fmt.Errorf("decode %s api_version: %w", src.Path, err)
+		}
+	}
+
+	sm.mu.RLock()
+	m := sm.migrations
+	sm.mu.RUnlock()
+	if m == nil {
+		return data, from, nil
+	}
+	latest, outdated := m.Latest(adapterID, from)
+	if declared && outdated {
+		Log().Warnf("%s: api_version %q is outdated (current %q); run Migrate to upgrade\n",
+			src.Path, from, latest)
+	}
+	spec, hasSpec := doc["spec"]
+	if !outdated || (!hasSpec && !declared && !lowest) {
+		return data, from, nil
+	}
+
+	version := latest
+	if hasSpec || lowest {
+		var err error
+		if spec, version, err = m.Apply(adapterID, from, spec); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", src.Path, err)
+		}
+		if hasSpec || len(spec) > 0 {
+			doc["spec"] = spec
+		}
+	}
+	doc["api_version"], _ = json.Marshal(version)
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode %s: %w", src.Path, err)
+	}
+	return out, from, nil
+}
+
+// migrationAdapter returns the adapter whose migrations apply to h. Adapter
+// configs may leave out the adapter key and are named after the adapter.
+func migrationAdapter(h *MetaHeader) string {
+	if h.Adapter != "" {
+		return h.Adapter
+	}
+	return h.Name
+}
+
+// Migrations returns the registry's migrations.
+func (r *Registry) Migrations() *Migrations {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.migrations == nil {
+		r.migrations = NewMigrations()
+	}
+	return r.migrations
+}
+
+// RegisterMigration registers a spec migration for adapterID from api_version
+// from to api_version to.
+func (r *Registry) RegisterMigration(adapterID, from, to string, fn MigrateFunc) {
+	r.Migrations().Register(adapterID, from, to, fn)
+	// Query results were migrated without it.
+	if sm := r.searchMap; sm != nil {
+		sm.mu.Lock()
+		sm.invalidate()
+		sm.mu.Unlock()
+	}
+}
+
+// Migrate rewrites outdated config files on disk to the latest api_version,
+// keeping a copy of each original next to it with a ".bak" suffix. Without
+// names every indexed config is migrated. It returns the rewritten files.
+func (r *Registry) Migrate(names ...string) ([]string, error) {
+	sm := r.searchMap
+	if sm == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	m := r.Migrations()
+	if len(names) == 0 {
+		names = sm.Keys()
+	}
+
+	var (
+		migrated []string
+		errs     []error
+	)
+	for _, name := range names {
+		meta, err := sm.Load(name, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		adapterID := migrationAdapter(meta)
+		// Lowest first: files without an api_version inherit the one below.
+		var inherited string
+		for i := len(meta.Sources) - 1; i >= 0; i-- {
+			src := meta.Sources[i]
+			ok, from, err := sm.migrateFile(m, adapterID, src, inherited)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if ok {
+				migrated = append(migrated, src.Path)
+			}
+			inherited = from
+		}
+	}
+	return migrated, errors.Join(errs...)
+}
+
+// migrateFile upgrades a single config file from the api_version it
+// declares, or inherited without one, and reports whether it was rewritten
+// and the version its spec was written in.
+func (sm *SearchMap) migrateFile(m *Migrations, adapterID string, src ConfigSource, inherited string) (bool, string, error) {
+	data, err := sm.ReadSource(src)
+	if err != nil {
+		return false, "", fmt.Errorf("read %s: %w", src.Path, err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false, "", fmt.Errorf("decode %s: %w", src.Path, err)
+	}
+
+	from := inherited
+	v, declared := doc["api_version"]
+	if declared {
+		if err := json.Unmarshal(v, &from); err != nil {
+			return false, "", fmt.Errorf("decode %s api_version: %w", src.Path, err)
+		}
+	}
+	_, hasSpec := doc["spec"]
+	if _, ok := m.Latest(adapterID, from); !ok || (!declared && !hasSpec) {
+		return false, from, nil
+	}
+	wfs, ok := sm.layers[src.layer].fs.(WritableFileSystem)
+	if !ok {
+		Log().Warnf("%s: cannot migrate a file on a read-only filesystem\n", src.Path)
+		return false, from, nil
+	}
+
+	// Files without a spec, like overrides of other keys, only get the new version.
+	version, _ := m.Latest(adapterID, from)
+	var fields []objectField
+	if spec, ok := doc["spec"]; ok {
+		if spec, version, err = m.Apply(adapterID, from, spec); err != nil {
+			return false, "", fmt.Errorf("%s: %w", src.Path, err)
+		}
+		fields = append(fields, objectField{key: "spec", value: spec})
+	}
+	rawVersion, _ := json.Marshal(version)
+	fields = append(fields, objectField{key: "api_version", value: rawVersion})
+
+	out, err := patchObject(data, fields)
+	if err != nil {
+		return false, "", fmt.Errorf("encode %s: %w", src.Path, err)
+	}
+	perm := fileMode(wfs, src.Path, 0o644)
+	if err := wfs.WriteFile(src.Path+".bak", data, perm); err != nil {
+		return false, "", fmt.Errorf("backup %s: %w", src.Path, err)
+	}
+	if err := wfs.WriteFile(src.Path, out, perm); err != nil {
+		return false, "", fmt.Errorf("write %s: %w", src.Path, err)
+	}
+	Log().Infof("migrated %s from api_version %q to %q\n", src.Path, from, version)
+	return true, from, nil
+}
+
+// RegisterMigration registers a spec migration on the default registry.
+func RegisterMigration(adapterID, from, to string, fn MigrateFunc) {
+	defaultRegistry.RegisterMigration(adapterID, from, to, fn)
+}
+
+// Migrate rewrites outdated config files of the default registry.
+func Migrate(names ...string) ([]string, error) {
+	return defaultRegistry.Migrate(names...)
+}
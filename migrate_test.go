@@ -0,0 +1,173 @@
+package core_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// MigratedAdp renamed its "host" field to "address" in v2 and added a port in v3.
+type MigratedAdp struct {
+	Spec struct {
+		Address string `json:"address"`
+		Port    int    `json:"port"`
+	}
+}
+
+func (a *MigratedAdp) ConfigPtr() any { return &a.Spec }
+
+func TestRegistry_Migrate(t *testing.T) {
+	core.Register("migrated-adp", func() core.Adapter { return &MigratedAdp{} })
+	core.RegisterMigration("migrated-adp", "v1", "v2", func(spec json.RawMessage) (json.RawMessage, error) {
+		var old map[string]any
+		if err := json.Unmarshal(spec, &old); err != nil {
+			return nil, err
+		}
+		old["address"] = old["host"]
+		delete(old, "host")
+		return json.Marshal(old)
+	})
+	core.RegisterMigration("migrated-adp", "v2", "v3", func(spec json.RawMessage) (json.RawMessage, error) {
+		var old map[string]any
+		if err := json.Unmarshal(spec, &old); err != nil {
+			return nil, err
+		}
+		old["port"] = 80
+		return json.Marshal(old)
+	})
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "migrated-adp.json")
+	original := `{"api_version":"v1","spec":{"host":"example.com"}}`
+	writeFile(t, cfg, original)
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	// Load upgrades in memory only.
+	meta, err := sm.Load("migrated-adp", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := string(meta.RawSpec), `{"address":"example.com","port":80}`; got != want || meta.APIVersion != "v3" {
+		t.Fatalf("Load = %s (%s), want %s (v3)", got, meta.APIVersion, want)
+	}
+	a, err := core.NewAdapterAs[*MigratedAdp]("migrated-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.Spec.Address != "example.com" || a.Spec.Port != 80 {
+		t.Fatalf("Spec = %+v", a.Spec)
+	}
+
+	if err := os.Chmod(cfg, 0o600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	migrated, err := core.Migrate("migrated-adp")
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	info, err := os.Stat(cfg)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Fatalf("migrated file mode = %v, want 0600", info.Mode())
+	}
+	if len(migrated) != 1 || migrated[0] != cfg {
+		t.Fatalf("Migrate = %v, want [%s]", migrated, cfg)
+	}
+	backup, err := os.ReadFile(cfg + ".bak")
+	if err != nil || string(backup) != original {
+		t.Fatalf("backup = %q, %v", backup, err)
+	}
+	data, _ := os.ReadFile(cfg)
+	if got, want := string(data), `{"api_version":"v3","spec":{"address":"example.com","port":80}}`+"\n"; got != want {
+		t.Fatalf("migrated file = %s", data)
+	}
+
+	// Up to date files are left alone.
+	if migrated, err := core.Migrate(); err != nil || len(migrated) != 0 {
+		t.Fatalf("second Migrate = %v, %v", migrated, err)
+	}
+}
+
+func TestRegistry_SetSearchMapNil(t *testing.T) {
+	r := core.DefaultRegistry()
+	r.SetSearchMap(nil)
+	if _, err := r.Migrate(); err == nil {
+		t.Fatalf("Migrate without a SearchMap succeeded, want an error")
+	}
+}
+
+func TestSearchMap_MigratesEachRoot(t *testing.T) {
+	core.Register("layered-migrated-adp", func() core.Adapter { return &MigratedAdp{} })
+	core.RegisterMigration("layered-migrated-adp", "v1", "v2", func(spec json.RawMessage) (json.RawMessage, error) {
+		var old map[string]any
+		if err := json.Unmarshal(spec, &old); err != nil {
+			return nil, err
+		}
+		if host, ok := old["host"]; ok {
+			old["address"] = host
+			delete(old, "host")
+		}
+		return json.Marshal(old)
+	})
+
+	dir := t.TempDir()
+	project, system := filepath.Join(dir, "project"), filepath.Join(dir, "system")
+	writeFile(t, filepath.Join(system, "layered-migrated-adp.json"), `{"api_version":"v1","spec":{"host":"system","port":80}}`)
+	writeFile(t, filepath.Join(project, "layered-migrated-adp.json"), `{"api_version":"v2","spec":{"address":"project"}}`)
+	// An override without api_version is written in the version below it.
+	writeFile(t, filepath.Join(dir, "local", "layered-migrated-adp.json"), `{"spec":{"host":"local"}}`)
+
+	sm, err := core.SetDefaultSearchPath(project, system)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	meta, err := sm.Load("layered-migrated-adp", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := string(meta.RawSpec), `{"address":"project","port":80}`; got != want || meta.APIVersion != "v2" {
+		t.Fatalf("Load = %s (%s), want %s (v2)", got, meta.APIVersion, want)
+	}
+
+	local := filepath.Join(dir, "local")
+	if sm, err = core.SetDefaultSearchPath(local, system); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if meta, err = sm.Load("layered-migrated-adp", false); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := string(meta.RawSpec), `{"address":"local","port":80}`; got != want {
+		t.Fatalf("Load = %s, want %s", got, want)
+	}
+	migrated, err := core.Migrate("layered-migrated-adp")
+	if err != nil || len(migrated) != 2 {
+		t.Fatalf("Migrate = %v, %v, want both files", migrated, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(local, "layered-migrated-adp.json"))
+	if got, want := string(data), `{"spec":{"address":"local"},"api_version":"v2"}`+"\n"; got != want {
+		t.Fatalf("migrated override = %s", data)
+	}
+}
+
+func TestMigrations_CurrentIsStable(t *testing.T) {
+	noop := func(spec json.RawMessage) (json.RawMessage, error) { return spec, nil }
+	for i := 0; i < 20; i++ {
+		m := core.NewMigrations()
+		// Two separate chains: the longer one wins.
+		m.Register("svc", "v1", "v2", noop)
+		m.Register("svc", "v2", "v3", noop)
+		m.Register("svc", "legacy", "v9", noop)
+		if got := m.Current("svc"); got != "v3" {
+			t.Fatalf("Current = %q, want v3", got)
+		}
+	}
+}
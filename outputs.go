@@ -0,0 +1,182 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StateDir is the directory, under the work dir base or the first search
+// root, where core keeps state between CLI invocations. It is never indexed.
+const StateDir = ".core-state"
+
+// outputsFileName is the file in StateDir that collected outputs persist to.
+const outputsFileName = "outputs.json"
+
+// OutputRecord is the persisted outputs of one adapter or item.
+type OutputRecord struct {
+	Adapter   string         `json:"adapter"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Outputs   map[string]any `json:"outputs"`
+}
+
+// errNoStateDir is returned when a SearchMap has nowhere to keep state.
+var errNoStateDir = errors.New("core: no work dir base or OS search root to keep state in")
+
+// stateDir returns the directory core state is kept in: StateDir under the
+// work dir base, or under the highest precedence search root on the OS
+// filesystem.
+func (sm *SearchMap) stateDir() (string, error) {
+	if base := sm.WorkDirBase(); base != "" {
+		return filepath.Join(base, StateDir), nil
+	}
+	for _, l := range sm.layers {
+		if isLocalFS(l.fs) {
+			return filepath.Join(l.path, StateDir), nil
+		}
+	}
+	return "", errNoStateDir
+}
+
+// CollectOutputs builds the adapter for adapterID and args like NewAdapter,
+// reads its Outputs and persists them, so later runs and $output references
+// can use them without the adapter producing them again.
+func (r *Registry) CollectOutputs(ctx context.Context, adapterID string, args ...string) (map[string]any, error) {
+	a, err := r.newAdapter(ctx, adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := a.(Outputter)
+	if !ok {
+		return nil, fmt.Errorf("adapter %s has no outputs", adapterID)
+	}
+	outputs, err := o.Outputs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("outputs of %s: %w", adapterID, err)
+	}
+
+	key, err := r.requestKey(adapterID, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.saveOutputs(key, adapterID, outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// saveOutputs persists the outputs of key.
+func (r *Registry) saveOutputs(key, adapterID string, outputs map[string]any) error {
+	return r.updateOutputs(func(records map[string]OutputRecord) {
+		records[key] = OutputRecord{Adapter: strings.ToLower(adapterID), UpdatedAt: time.Now().UTC(), Outputs: outputs}
+	})
+}
+
+// SavedOutputs returns the outputs last collected for key: the full key of an
+// item config, as state is keyed, or the lower-case adapter ID. It returns an error wrapping os.ErrNotExist when there are
+// none.
+func (r *Registry) SavedOutputs(key string) (*OutputRecord, error) {
+	records, err := r.readOutputs()
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := records[key]
+	if !ok {
+		return nil, fmt.Errorf("no outputs saved for %s: %w", key, os.ErrNotExist)
+	}
+	return &rec, nil
+}
+
+// ForgetOutputs removes the persisted outputs of key, e.g. after a Delete.
+func (r *Registry) ForgetOutputs(key string) error {
+	return r.updateOutputs(func(records map[string]OutputRecord) {
+		delete(records, key)
+	})
+}
+
+func (r *Registry) outputsFile() (string, error) {
+	if r.searchMap == nil {
+		return "", fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	dir, err := r.searchMap.stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, outputsFileName), nil
+}
+
+func (r *Registry) readOutputs() (map[string]OutputRecord, error) {
+	records := make(map[string]OutputRecord)
+	file, err := r.outputsFile()
+	if errors.Is(err, errNoStateDir) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", file, err)
+	}
+	return records, nil
+}
+
+// updateOutputs applies fn to the persisted outputs and writes them back. The
+// outputs file is locked meanwhile, so concurrent runs don't lose updates.
+func (r *Registry) updateOutputs(fn func(records map[string]OutputRecord)) error {
+	r.outputsMu.Lock()
+	defer r.outputsMu.Unlock()
+
+	file, err := r.outputsFile()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(context.Background(), file+".lock")
+	if err != nil {
+		return fmt.Errorf("lock %s: %w", file, err)
+	}
+	defer unlock()
+
+	records, err := r.readOutputs()
+	if err != nil {
+		return err
+	}
+	fn(records)
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := (osFS{}).WriteFile(file, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
+
+// CollectOutputs collects and persists the outputs of an adapter in the
+// default registry.
+func CollectOutputs(ctx context.Context, adapterID string, args ...string) (map[string]any, error) {
+	return defaultRegistry.CollectOutputs(ctx, adapterID, args...)
+}
+
+// SavedOutputs returns the outputs last collected for key in the default registry.
+func SavedOutputs(key string) (*OutputRecord, error) {
+	return defaultRegistry.SavedOutputs(key)
+}
+
+// ForgetOutputs removes the persisted outputs of key in the default registry.
+func ForgetOutputs(key string) error {
+	return defaultRegistry.ForgetOutputs(key)
+}
@@ -0,0 +1,138 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+func TestRegistry_CollectOutputs(t *testing.T) {
+	core.Register("out-storage", func() core.Adapter { return &StorageAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "out-storage.json"), `{"spec":{"bucket":"media"}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	outputs, err := core.CollectOutputs(context.Background(), "out-storage")
+	if err != nil {
+		t.Fatalf("CollectOutputs: %v", err)
+	}
+	if got := outputs["endpoint"]; got != "s3://media" {
+		t.Fatalf("endpoint = %v, want s3://media", got)
+	}
+
+	// A fresh search map reads them back from the state file.
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	rec, err := core.SavedOutputs("out-storage")
+	if err != nil {
+		t.Fatalf("SavedOutputs: %v", err)
+	}
+	if rec.Adapter != "out-storage" || rec.Outputs["endpoint"] != "s3://media" {
+		t.Fatalf("saved = %+v", rec)
+	}
+	if keys := sm.Keys(); len(keys) != 1 {
+		t.Fatalf("Keys = %v, want the state dir unindexed", keys)
+	}
+
+	if err := core.ForgetOutputs("out-storage"); err != nil {
+		t.Fatalf("ForgetOutputs: %v", err)
+	}
+	if _, err := core.SavedOutputs("out-storage"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("SavedOutputs after forget err = %v", err)
+	}
+}
+
+// CountingStorageAdp counts how often its outputs are read, across instances,
+// since a cached one may be reused by later runs.
+type CountingStorageAdp struct{ StorageAdp }
+
+var countingStorageCalls int
+
+func (a *CountingStorageAdp) Outputs(ctx context.Context) (map[string]any, error) {
+	countingStorageCalls++
+	return a.StorageAdp.Outputs(ctx)
+}
+
+func TestRegistry_OutputRefUsesSavedOutputs(t *testing.T) {
+	core.Register("saved-storage", func() core.Adapter { return &CountingStorageAdp{} })
+	core.Register("saved-consumer", func() core.Adapter { return &ConsumerAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "saved-storage.json"), `{"spec":{"bucket":"logs"}}`)
+	writeFile(t, filepath.Join(dir, "saved-consumer.json"), `{"spec":{"endpoint":{"$output":"saved-storage#/endpoint"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	before := countingStorageCalls
+	if _, err := core.CollectOutputs(context.Background(), "saved-storage"); err != nil {
+		t.Fatalf("CollectOutputs: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, core.StateDir, "outputs.json"))
+		if err != nil {
+			t.Fatalf("stat outputs: %v", err)
+		}
+		if got := info.Mode().Perm(); got != 0o600 {
+			t.Fatalf("outputs mode = %v, want 0600", got)
+		}
+	}
+
+	a, err := core.NewAdapterAs[*ConsumerAdp]("saved-consumer")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if got := a.Spec.Endpoint; got != "s3://logs" {
+		t.Fatalf("Endpoint = %q, want s3://logs", got)
+	}
+	if calls := countingStorageCalls - before; calls != 1 {
+		t.Fatalf("Outputs called %d times, want once: the $output should use the saved outputs", calls)
+	}
+}
+
+// ItemStorageAdp is a CountingStorageAdp configured per item.
+type ItemStorageAdp struct{ CountingStorageAdp }
+
+func (a *ItemStorageAdp) ItemConfigPtr(name string) any { return &a.Spec }
+
+func TestRegistry_OutputsKeyedByConfig(t *testing.T) {
+	storeID, consumerID := runID("item-storage"), runID("item-consumer")
+	core.Register(storeID, func() core.Adapter { return &ItemStorageAdp{} })
+	core.Register(consumerID, func() core.Adapter { return &ConsumerAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "stores", "main.json"), fmt.Sprintf(`{"adapter":%q,"spec":{"bucket":"main"}}`, storeID))
+	writeFile(t, filepath.Join(dir, consumerID+".json"), `{"spec":{"endpoint":{"$output":"stores/main#/endpoint"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	// Collected under the short name, found under the full key.
+	before := countingStorageCalls
+	if _, err := core.CollectOutputs(context.Background(), storeID, "main"); err != nil {
+		t.Fatalf("CollectOutputs: %v", err)
+	}
+	if _, err := core.SavedOutputs("stores/main"); err != nil {
+		t.Fatalf("SavedOutputs: %v", err)
+	}
+	a, err := core.NewAdapterAs[*ConsumerAdp](consumerID)
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if got := a.Spec.Endpoint; got != "s3://main" {
+		t.Fatalf("Endpoint = %q, want s3://main", got)
+	}
+	if calls := countingStorageCalls - before; calls != 1 {
+		t.Fatalf("Outputs called %d times, want once: the $output should use the saved outputs", calls)
+	}
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mount maps a host directory onto a directory inside a container.
+type Mount struct {
+	Host      string
+	Container string
+}
+
+// PathMapper translates paths between the host and a container using a
+// prefix-based mount table. When core runs inside a container, config files
+// are read at container paths while adapters work with host paths (see
+// WorkDirSettable), so resolved work dirs are translated to the host.
+//
+// The zero value and nil map nothing.
+type PathMapper struct {
+	mounts []Mount
+}
+
+// NewPathMapper returns a mapper for mounts. Paths are cleaned; the longest
+// matching prefix wins.
+func NewPathMapper(mounts ...Mount) *PathMapper {
+	pm := &PathMapper{}
+	for _, m := range mounts {
+		pm.mounts = append(pm.mounts, Mount{
+			Host:      filepath.Clean(m.Host),
+			Container: filepath.Clean(m.Container),
+		})
+	}
+	return pm
+}
+
+// ParsePathMap parses a comma-separated mount table of host:container pairs,
+// as in the CORE_PATH_MAP environment variable:
+//
+//	CORE_PATH_MAP=/home/me/src:/workspace,/home/me/.cache:/cache
+func ParsePathMap(s string) (*PathMapper, error) {
+	var mounts []Mount
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		// Split on the last colon so Windows host paths (C:\src) work.
+		i := strings.LastIndex(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid mount %q: want host:container", pair)
+		}
+		mounts = append(mounts, Mount{Host: pair[:i], Container: pair[i+1:]})
+	}
+	return NewPathMapper(mounts...), nil
+}
+
+// Mounts returns the mount table.
+func (pm *PathMapper) Mounts() []Mount {
+	if pm == nil {
+		return nil
+	}
+	return append([]Mount(nil), pm.mounts...)
+}
+
+// ToHost translates a container path to the host. Paths outside every mount
+// are returned cleaned but otherwise unchanged.
+func (pm *PathMapper) ToHost(p string) string {
+	return pm.translate(p, func(m Mount) (string, string) { return m.Container, m.Host })
+}
+
+// ToContainer translates a host path into the container. Paths outside every
+// mount are returned cleaned but otherwise unchanged.
+func (pm *PathMapper) ToContainer(p string) string {
+	return pm.translate(p, func(m Mount) (string, string) { return m.Host, m.Container })
+}
+
+func (pm *PathMapper) translate(p string, dir func(Mount) (from, to string)) string {
+	if p == "" {
+		return p
+	}
+	p = filepath.Clean(p)
+	if pm == nil {
+		return p
+	}
+
+	best, bestLen := "", -1
+	for _, m := range pm.mounts {
+		from, to := dir(m)
+		rest, ok := cutPathPrefix(p, from)
+		if !ok || len(from) <= bestLen {
+			continue
+		}
+		best, bestLen = filepath.Join(to, rest), len(from)
+	}
+	if bestLen < 0 {
+		return p
+	}
+	return best
+}
+
+// cutPathPrefix returns p relative to prefix if p is prefix or inside it.
+func cutPathPrefix(p, prefix string) (string, bool) {
+	if p == prefix {
+		return "", true
+	}
+	sep := string(filepath.Separator)
+	if !strings.HasSuffix(prefix, sep) {
+		prefix += sep
+	}
+	rest, ok := strings.CutPrefix(p, prefix)
+	return rest, ok
+}
+
+// WorkerPathMapper maps a worker's host work dir onto its InternalWorkDir, so
+// adapters can translate their own paths for the worker:
+//
+//	pm := core.WorkerPathMapper(w, w.WorkDir)
+//	args = append(args, pm.ToContainer(configFile))
+func WorkerPathMapper(w Worker, hostWorkDir string) *PathMapper {
+	internal := w.InternalWorkDir()
+	if hostWorkDir == "" || internal == "" {
+		return NewPathMapper()
+	}
+	return NewPathMapper(Mount{Host: hostWorkDir, Container: internal})
+}
+
+// defaultPathMapper is filled from the CORE_PATH_MAP env.
+var defaultPathMapper = NewPathMapper()
+
+func init() {
+	if s := os.Getenv("CORE_PATH_MAP"); s != "" {
+		pm, err := ParsePathMap(s)
+		if err != nil {
+			Log().Warnf("ignoring CORE_PATH_MAP: %v\n", err)
+			return
+		}
+		defaultPathMapper = pm
+	}
+}
+
+// DefaultPathMapper returns the mapper configured through CORE_PATH_MAP. It
+// is used by SearchMaps without their own mapper.
+func DefaultPathMapper() *PathMapper {
+	return defaultPathMapper
+}
+
+// SetDefaultPathMapper replaces the mapper configured through CORE_PATH_MAP.
+func SetDefaultPathMapper(pm *PathMapper) {
+	defaultPathMapper = pm
+}
+
+// HostPath translates a container path to the host with the default
+// registry's mapper.
+func HostPath(p string) string {
+	return registryPathMapper(defaultRegistry).ToHost(p)
+}
+
+// ContainerPath translates a host path into the container with the default
+// registry's mapper.
+func ContainerPath(p string) string {
+	return registryPathMapper(defaultRegistry).ToContainer(p)
+}
+
+func registryPathMapper(r *Registry) *PathMapper {
+	if r.searchMap != nil {
+		return r.searchMap.PathMapper()
+	}
+	return defaultPathMapper
+}
+
+// SetPathMapper sets the mapper that resolved work dirs are translated to the
+// host with.
+func (sm *SearchMap) SetPathMapper(pm *PathMapper) {
+	sm.mu.Lock()
+	sm.pathMapper = pm
+	sm.invalidate()
+	sm.mu.Unlock()
+}
+
+// PathMapper returns the SearchMap's mapper, or the default one.
+func (sm *SearchMap) PathMapper() *PathMapper {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if sm.pathMapper != nil {
+		return sm.pathMapper
+	}
+	return defaultPathMapper
+}
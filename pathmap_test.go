@@ -0,0 +1,113 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+func TestPathMapper(t *testing.T) {
+	pm, err := core.ParsePathMap("/home/me/src:/workspace, /home/me/src/cache:/cache")
+	if err != nil {
+		t.Fatalf("ParsePathMap: %v", err)
+	}
+	for _, tc := range []struct{ host, container string }{
+		{"/home/me/src", "/workspace"},
+		{"/home/me/src/app", "/workspace/app"},
+		{"/home/me/src/cache/x", "/cache/x"}, // longest prefix wins
+		{"/home/me/srcx", "/home/me/srcx"},   // prefixes match whole elements
+		{"/etc", "/etc"},
+	} {
+		if got := pm.ToContainer(tc.host); got != tc.container {
+			t.Errorf("ToContainer(%s) = %s, want %s", tc.host, got, tc.container)
+		}
+		if got := pm.ToHost(tc.container); got != tc.host {
+			t.Errorf("ToHost(%s) = %s, want %s", tc.container, got, tc.host)
+		}
+	}
+	if _, err := core.ParsePathMap("/only-host"); err == nil {
+		t.Errorf("ParsePathMap(/only-host): expected error")
+	}
+}
+
+// MountedAdp receives host work dirs for itself and its dependency.
+type MountedAdp struct {
+	WorkDir string
+	Dep     *MountedDep `core:"mounted-dep"`
+}
+
+func (a *MountedAdp) SetWorkDir(p string) { a.WorkDir = p }
+
+type MountedDep struct{ WorkDir string }
+
+func (d *MountedDep) SetWorkDir(p string) { d.WorkDir = p }
+
+func TestSearchMap_PathMapper(t *testing.T) {
+	core.Register("mounted-adp", func() core.Adapter { return &MountedAdp{} })
+	core.Register("mounted-dep", func() core.Adapter { return &MountedDep{} })
+
+	// dir plays the container side of the mount.
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "mounted-adp.json")
+	writeFile(t, cfg, `{"work_dir":"app","dependencies":{"Dep":{"adapter":"mounted-dep","work_dir":"lib"}}}`)
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	sm.SetPathMapper(core.NewPathMapper(core.Mount{Host: "/home/me/src", Container: dir}))
+
+	a, err := core.NewAdapterAs[*MountedAdp]("mounted-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.WorkDir != "/home/me/src/app" {
+		t.Fatalf("WorkDir = %q, want host path", a.WorkDir)
+	}
+	if a.Dep == nil || a.Dep.WorkDir != "/home/me/src/app/lib" {
+		t.Fatalf("Dep = %+v, want host path /home/me/src/app/lib", a.Dep)
+	}
+	if got := core.ContainerPath(a.WorkDir); got != filepath.Join(dir, "app") {
+		t.Fatalf("ContainerPath = %q", got)
+	}
+
+	// Saving writes the work dir relative to the file again.
+	meta, err := sm.Load("mounted-adp", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := sm.Save(meta, "mounted-adp"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, _ := os.ReadFile(cfg)
+	if !strings.Contains(string(data), `"work_dir":"app"`) {
+		t.Fatalf("saved = %s", data)
+	}
+}
+
+func TestSearchMap_PathMapperOverlappingMounts(t *testing.T) {
+	core.Register("overlap-adp", func() core.Adapter { return &MountedAdp{} })
+	core.Register("overlap-dep", func() core.Adapter { return &MountedDep{} })
+
+	// The host side lies inside the container side, so mapping twice shows.
+	dir := t.TempDir()
+	host := filepath.Join(dir, "host")
+	writeFile(t, filepath.Join(dir, "overlap-adp.json"),
+		`{"work_dir":"p","dependencies":{"Dep":{"adapter":"overlap-dep"}}}`)
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	sm.SetPathMapper(core.NewPathMapper(core.Mount{Host: host, Container: dir}))
+
+	a, err := core.NewAdapterAs[*MountedAdp]("overlap-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	want := filepath.Join(host, "p")
+	if a.WorkDir != want || a.Dep == nil || a.Dep.WorkDir != want {
+		t.Fatalf("WorkDir = %q, Dep = %+v, want both at %s", a.WorkDir, a.Dep, want)
+	}
+}
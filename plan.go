@@ -0,0 +1,341 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ActionNoop is planned for items that are up to date.
+const ActionNoop = "no-op"
+
+// planVersion is the plan file format version.
+const planVersion = 1
+
+// ErrStalePlan is returned by Apply when configs changed since the plan was
+// made.
+var ErrStalePlan = errors.New("configs changed since the plan was made")
+
+// PlannedAction is what a Planner intends to do with its item.
+type PlannedAction struct {
+	Action  string         `json:"action"` // ActionCreate, ActionUpdate, ActionDelete or ActionNoop
+	Reason  string         `json:"reason,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Target is an adapter request to plan, with args as in NewAdapter.
+type Target struct {
+	Adapter string   `json:"adapter"`
+	Args    []string `json:"args,omitempty"`
+	Delete  bool     `json:"delete,omitempty"` // plan its deletion instead
+}
+
+// PlanStep is one planned action.
+type PlanStep struct {
+	Target
+	Key     string         `json:"key"` // state key of the item
+	Action  string         `json:"action"`
+	Reason  string         `json:"reason,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+	Changes []FieldChange  `json:"changes,omitempty"`
+	Digest  string         `json:"digest"` // effective config when planned
+}
+
+// Plan is the ordered actions Apply performs: dependencies before the items
+// using them, deletions last.
+type Plan struct {
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	Steps     []PlanStep `json:"steps"`
+}
+
+// Changed reports whether applying the plan would do anything.
+func (p *Plan) Changed() bool {
+	for _, s := range p.Steps {
+		if s.Action != ActionNoop {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText renders the plan one step per line with a summary.
+func (p *Plan) WriteText(w io.Writer) error {
+	counts := make(map[string]int)
+	for _, s := range p.Steps {
+		counts[s.Action]++
+		mark := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}[s.Action]
+		if mark == "" {
+			mark = " "
+		}
+		line := fmt.Sprintf("%s %s %s (%s)", mark, s.Action, s.Key, s.Adapter)
+		if s.Reason != "" {
+			line += ": " + s.Reason
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, c := range s.Changes {
+			if _, err := fmt.Fprintf(w, "    %s %s: %s -> %s\n", c.Kind, c.Pointer, jsonText(c.Old), jsonText(c.New)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "plan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete], counts[ActionNoop])
+	return err
+}
+
+// Save writes the plan file.
+func (p *Plan) Save(file string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := (osFS{}).WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write plan %s: %w", file, err)
+	}
+	return nil
+}
+
+// LoadPlan reads a plan file written by Plan.Save.
+func LoadPlan(file string) (*Plan, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("decode plan %s: %w", file, err)
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("plan %s has version %d, want %d", file, p.Version, planVersion)
+	}
+	return &p, nil
+}
+
+// Plan works out what Apply would do for the targets and the dependencies
+// they are wired with. Planners decide for their own items; for other
+// adapters an item that was never applied is created and one whose spec
+// differs from the applied spec (see Diff) is updated. Building the targets
+// hydrates them, as NewAdapter does.
+func (r *Registry) Plan(ctx context.Context, targets ...Target) (*Plan, error) {
+	p := &Plan{Version: planVersion, CreatedAt: time.Now().UTC(), Steps: []PlanStep{}}
+	seen := make(map[string]bool)
+	var deletes []PlanStep
+
+	var visit func(t Target) error
+	visit = func(t Target) error {
+		key, err := r.requestKey(t.Adapter, t.Args...)
+		if err != nil {
+			return err
+		}
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		a, err := r.newAdapter(ctx, t.Adapter, t.Args...)
+		if err != nil {
+			return err
+		}
+		if !t.Delete {
+			// Dependencies first.
+			for _, dep := range r.depTargets(a) {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, planner := a.(Planner)
+		_, creater := a.(Creater)
+		_, updater := a.(Updater)
+		_, deleter := a.(Deleter)
+		if !planner && !creater && !updater && !(t.Delete && deleter) {
+			return nil // nothing to apply
+		}
+		step, err := r.planStep(ctx, a, key, t)
+		if err != nil {
+			return err
+		}
+		if step.Action == ActionDelete {
+			deletes = append(deletes, step)
+		} else {
+			p.Steps = append(p.Steps, step)
+		}
+		return nil
+	}
+	for _, t := range targets {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+
+	// Delete dependents before what they depend on.
+	slices.Reverse(deletes)
+	p.Steps = append(p.Steps, deletes...)
+	return p, nil
+}
+
+func (r *Registry) planStep(ctx context.Context, a Adapter, key string, t Target) (PlanStep, error) {
+	step := PlanStep{Target: t, Key: key}
+	step.Adapter = strings.ToLower(t.Adapter)
+
+	digest, err := r.configDigest(ctx, t)
+	if err != nil {
+		return step, err
+	}
+	step.Digest = digest
+
+	d, err := r.Diff(ctx, t.Adapter, t.Args...)
+	if err != nil {
+		return step, err
+	}
+
+	// A Planner decides how to converge, not whether to delete.
+	if t.Delete {
+		step.Action = ActionDelete
+		if !d.Recorded {
+			step.Reason = "not applied by core"
+		}
+		return step, nil
+	}
+
+	if planner, ok := a.(Planner); ok {
+		pa, err := planner.Plan(ctx, t.Args...)
+		if err != nil {
+			return step, fmt.Errorf("plan %s: %w", key, err)
+		}
+		if pa == nil {
+			pa = &PlannedAction{Action: ActionNoop}
+		}
+		step.Action, step.Reason, step.Details = pa.Action, pa.Reason, pa.Details
+		if pa.Action == ActionUpdate {
+			step.Changes = d.Changes
+		}
+		switch step.Action {
+		case ActionCreate, ActionUpdate, ActionDelete, ActionNoop:
+		default:
+			return step, fmt.Errorf("plan %s: unknown action %q", key, step.Action)
+		}
+		return step, nil
+	}
+
+	switch {
+	case !d.Recorded:
+		step.Action, step.Reason = ActionCreate, "never applied"
+	case d.Changed():
+		step.Action, step.Changes = ActionUpdate, d.Changes
+		step.Reason = fmt.Sprintf("%d field(s) changed", len(d.Changes))
+	default:
+		step.Action = ActionNoop
+	}
+	return step, nil
+}
+
+// depTargets returns the requests of the cached adapters injected into a.
+func (r *Registry) depTargets(a Adapter) []Target {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var parent string
+	for key, cached := range r.adapters {
+		if cached == a {
+			parent = key
+			break
+		}
+	}
+	var out []Target
+	for _, key := range sortedKeys(r.entries) {
+		e := r.entries[key]
+		if parent != "" && e.dependents[parent] {
+			out = append(out, Target{Adapter: e.adapterID, Args: slices.Clone(e.args)})
+		}
+	}
+	return out
+}
+
+// configDigest fingerprints the effective config of a request, including the
+// digests of its resolved secret and output values. Work dirs are left out so
+// a plan can be applied from another checkout.
+func (r *Registry) configDigest(ctx context.Context, t Target) (string, error) {
+	e, err := r.Explain(t.Adapter, t.Args...)
+	if err != nil {
+		return "", err
+	}
+	refs, err := r.refDigests(ctx, t.Adapter, t.Args...)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Dependencies map[string]DepRef `json:"dependencies,omitempty"`
+		Spec         json.RawMessage   `json:"spec,omitempty"`
+		RefDigests   map[string]string `json:"ref_digests,omitempty"`
+	}{e.Dependencies, e.Spec, refs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Apply performs the plan's steps in order, stopping at the first failure.
+// It refuses to start, with an error wrapping ErrStalePlan, if the effective
+// config of any step changed since the plan was made.
+func (r *Registry) Apply(ctx context.Context, p *Plan) error {
+	if p.Version != planVersion {
+		return fmt.Errorf("plan has version %d, want %d", p.Version, planVersion)
+	}
+	var stale []string
+	for _, s := range p.Steps {
+		digest, err := r.configDigest(ctx, s.Target)
+		if err != nil {
+			return fmt.Errorf("check %s: %w", s.Key, err)
+		}
+		if digest != s.Digest {
+			stale = append(stale, s.Key)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("%w: %s; plan again", ErrStalePlan, strings.Join(stale, ", "))
+	}
+
+	for _, s := range p.Steps {
+		var err error
+		switch s.Action {
+		case ActionNoop:
+			continue
+		case ActionCreate:
+			err = r.Create(ctx, s.Adapter, s.Args...)
+		case ActionUpdate:
+			err = r.Update(ctx, s.Adapter, s.Args...)
+		case ActionDelete:
+			err = r.Delete(ctx, s.Adapter, s.Args...)
+		default:
+			err = fmt.Errorf("unknown action %q", s.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("apply %s: %w", s.Key, err)
+		}
+	}
+	return nil
+}
+
+// PlanTargets plans the targets in the default registry.
+func PlanTargets(ctx context.Context, targets ...Target) (*Plan, error) {
+	return defaultRegistry.Plan(ctx, targets...)
+}
+
+// Apply applies a plan in the default registry.
+func Apply(ctx context.Context, p *Plan) error {
+	return defaultRegistry.Apply(ctx, p)
+}
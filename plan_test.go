@@ -0,0 +1,152 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// PlanDB is a Lifecycle adapter other adapters depend on.
+type PlanDB struct {
+	Spec struct {
+		Size string `json:"size"`
+	}
+	created int
+}
+
+func (a *PlanDB) ConfigPtr() any                                 { return &a.Spec }
+func (a *PlanDB) Create(ctx context.Context, in ...string) error { a.created++; return nil }
+func (a *PlanDB) Update(ctx context.Context, in ...string) error { return nil }
+func (a *PlanDB) Delete(ctx context.Context, in ...string) error { return nil }
+
+// PlanApp is a Lifecycle adapter using PlanDB.
+type PlanApp struct {
+	Store *PlanDB `core:"plan-db"`
+	Spec  struct {
+		Image string `json:"image"`
+	}
+}
+
+func (a *PlanApp) ItemConfigPtr(name string) any                  { return &a.Spec }
+func (a *PlanApp) Create(ctx context.Context, in ...string) error { return nil }
+func (a *PlanApp) Update(ctx context.Context, in ...string) error { return nil }
+func (a *PlanApp) Delete(ctx context.Context, in ...string) error { return nil }
+
+func TestRegistry_PlanApply(t *testing.T) {
+	dbID, appID := runID("plan-db"), runID("plan-app")
+	core.Register(dbID, func() core.Adapter { return &PlanDB{} })
+	core.Register(appID, func() core.Adapter { return &PlanApp{} })
+	ctx := context.Background()
+	web := func(image string) string {
+		return fmt.Sprintf(`{"adapter":%q,"dependencies":{"Store":{"adapter":%q}},"spec":{"image":%q}}`, appID, dbID, image)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, dbID+".json"), `{"spec":{"size":"small"}}`)
+	writeFile(t, filepath.Join(dir, "apps", "web.json"), web("web:1"))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	target := core.Target{Adapter: appID, Args: []string{"apps/web"}}
+	plan, err := core.PlanTargets(ctx, target)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Key != dbID || plan.Steps[1].Key != "apps/web" {
+		t.Fatalf("steps = %+v, want %s then apps/web", plan.Steps, dbID)
+	}
+	for _, s := range plan.Steps {
+		if s.Action != core.ActionCreate {
+			t.Fatalf("%s action = %s, want create", s.Key, s.Action)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Save(file); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := core.LoadPlan(file)
+	if err != nil {
+		t.Fatalf("LoadPlan: %v", err)
+	}
+	if err := core.Apply(ctx, loaded); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	db, err := core.NewAdapterAs[*PlanDB](dbID)
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if db.created != 1 {
+		t.Fatalf("db created %d times, want 1", db.created)
+	}
+
+	if plan, err = core.PlanTargets(ctx, target); err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Changed() {
+		t.Fatalf("plan after apply = %+v, want no changes", plan.Steps)
+	}
+
+	// Configs edited after planning make the plan stale.
+	writeFile(t, filepath.Join(dir, "apps", "web.json"), web("web:1"))
+	if plan, err = core.PlanTargets(ctx, target); err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	writeFile(t, filepath.Join(dir, "apps", "web.json"), web("web:2"))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if err := core.Apply(ctx, plan); !errors.Is(err, core.ErrStalePlan) {
+		t.Fatalf("Apply stale plan err = %v, want ErrStalePlan", err)
+	}
+}
+
+// PlannedDB plans its own changes and always finds nothing to do.
+type PlannedDB struct{ PlanDB }
+
+func (a *PlannedDB) Plan(ctx context.Context, in ...string) (*core.PlannedAction, error) {
+	return &core.PlannedAction{Action: core.ActionNoop}, nil
+}
+
+func TestRegistry_PlanDeleteOverridesPlanner(t *testing.T) {
+	id := runID("planned-db")
+	core.Register(id, func() core.Adapter { return &PlannedDB{} })
+	if _, err := core.SetDefaultSearchPath(t.TempDir()); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	plan, err := core.PlanTargets(context.Background(), core.Target{Adapter: id, Delete: true})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Action != core.ActionDelete {
+		t.Fatalf("steps = %+v, want a delete", plan.Steps)
+	}
+}
+
+func TestRegistry_PlanStaleOnSecretChange(t *testing.T) {
+	id := runID("plan-secret-db")
+	core.Register(id, func() core.Adapter { return &PlanDB{} })
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, id+".json"), `{"spec":{"size":{"$secret":"env:PLAN_DB_SIZE"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	t.Setenv("PLAN_DB_SIZE", "small")
+	plan, err := core.PlanTargets(ctx, core.Target{Adapter: id})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	t.Setenv("PLAN_DB_SIZE", "large")
+	if err := core.Apply(ctx, plan); !errors.Is(err, core.ErrStalePlan) {
+		t.Fatalf("Apply after the secret changed err = %v, want ErrStalePlan", err)
+	}
+}
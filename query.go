@@ -0,0 +1,190 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// catalogueEntry is a parsed config kept between queries.
+type catalogueEntry struct {
+	key  string
+	meta *MetaHeader
+	spec any // decoded spec, for field selectors
+}
+
+// selector is one term of a query: field=value or field!=value.
+type selector struct {
+	field string
+	value string
+	not   bool
+}
+
+// Query returns the configs matching a comma-separated list of selectors,
+// ordered by key. Each selector is field=value or field!=value, where field
+// is one of adapter, name or api_version, "spec." followed by a dotted path
+// into the spec, "labels." followed by a label, or a bare label name:
+//
+//	sm.Query("adapter=adp,env=prod")
+//	sm.Query("adapter=adp,spec.region=eu-west-1,tier!=canary")
+//
+// Parsed configs are cached until the SearchMap is re-indexed, so edits show
+// up after Reindex, Registry.Refresh or Registry.Watch.
+func (sm *SearchMap) Query(query string) ([]*MetaHeader, error) {
+	sels, err := parseSelectors(query)
+	if err != nil {
+		return nil, err
+	}
+	return sm.query(sels)
+}
+
+func (sm *SearchMap) query(sels []selector) ([]*MetaHeader, error) {
+	entries, err := sm.catalogue()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*MetaHeader
+	for _, e := range entries {
+		if matchSelectors(e, sels) {
+			out = append(out, e.meta.clone())
+		}
+	}
+	return out, nil
+}
+
+// clone deep-copies the header, so callers can't change the catalogue.
+func (h *MetaHeader) clone() *MetaHeader {
+	cp := *h
+	cp.Labels = maps.Clone(h.Labels)
+	if h.Dependencies != nil {
+		cp.Dependencies = make(map[string]DepRef, len(h.Dependencies))
+		for name, dep := range h.Dependencies {
+			dep.Args = slices.Clone(dep.Args)
+			cp.Dependencies[name] = dep
+		}
+	}
+	cp.RawSpec = slices.Clone(h.RawSpec)
+	cp.Sources = slices.Clone(h.Sources)
+	cp.refs = slices.Clone(h.refs)
+	return &cp
+}
+
+// catalogue returns every parsed config, building it when stale.
+func (sm *SearchMap) catalogue() ([]*catalogueEntry, error) {
+	sm.mu.RLock()
+	entries, gen := sm.entries, sm.gen
+	sm.mu.RUnlock()
+	if entries != nil {
+		return entries, nil
+	}
+
+	entries = []*catalogueEntry{}
+	for _, key := range sm.Keys() {
+		meta, err := sm.Load(key, false)
+		if errors.Is(err, os.ErrNotExist) {
+			Log().Infof("could not find config for: %s\n", key)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading meta %q: %w", key, err)
+		}
+		e := &catalogueEntry{key: key, meta: meta}
+		if len(meta.RawSpec) > 0 {
+			if e.spec, err = decodeJSON(meta.RawSpec); err != nil {
+				return nil, fmt.Errorf("decode %s spec: %w", key, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	// Keep the result unless the index changed while building it.
+	sm.mu.Lock()
+	if sm.gen == gen {
+		sm.entries = entries
+	}
+	sm.mu.Unlock()
+	return entries, nil
+}
+
+// invalidate drops the parsed configs. Callers hold sm.mu.
+func (sm *SearchMap) invalidate() {
+	sm.entries = nil
+	sm.gen++
+}
+
+func parseSelectors(query string) ([]selector, error) {
+	var out []selector
+	for _, term := range strings.Split(query, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var s selector
+		field, value, ok := strings.Cut(term, "!=")
+		if ok {
+			s.not = true
+		} else if field, value, ok = strings.Cut(term, "="); !ok {
+			return nil, fmt.Errorf("invalid selector %q: want field=value or field!=value", term)
+		}
+		s.field, s.value = strings.TrimSpace(field), strings.TrimSpace(value)
+		if s.field == "" {
+			return nil, fmt.Errorf("invalid selector %q: missing field", term)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func matchSelectors(e *catalogueEntry, sels []selector) bool {
+	for _, s := range sels {
+		got, ok := selectorValue(e, s.field)
+		match := ok && got == s.value
+		if strings.EqualFold(s.field, "adapter") {
+			match = ok && strings.EqualFold(got, s.value)
+		}
+		if match == s.not {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorValue returns the value of field in a config, as a string.
+func selectorValue(e *catalogueEntry, field string) (string, bool) {
+	m := e.meta
+	switch lower := strings.ToLower(field); {
+	case lower == "adapter":
+		return m.Adapter, m.Adapter != ""
+	case lower == "name":
+		return m.Name, true
+	case lower == "api_version":
+		return m.APIVersion, m.APIVersion != ""
+	case strings.HasPrefix(lower, "spec."):
+		v, ok := lookupPointer(e.spec, jsonPointer(strings.Split(field[len("spec."):], ".")...))
+		if !ok {
+			return "", false
+		}
+		switch v.(type) {
+		case map[string]any, []any, nil:
+			return "", false
+		}
+		return fmt.Sprint(v), true
+	case strings.HasPrefix(lower, "labels."):
+		field = field[len("labels."):]
+	}
+	v, ok := m.Labels[field]
+	return v, ok
+}
+
+// Query returns the configs of the default registry's SearchMap matching query.
+func Query(query string) ([]*MetaHeader, error) {
+	sm := defaultRegistry.searchMap
+	if sm == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	return sm.Query(query)
+}
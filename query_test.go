@@ -0,0 +1,136 @@
+package core_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// QueryAdp is built once per matching item config.
+type QueryAdp struct {
+	Name string
+	Spec struct {
+		Region string `json:"region"`
+	}
+}
+
+func (a *QueryAdp) ItemConfigPtr(name string) any {
+	a.Name = name
+	return &a.Spec
+}
+
+func TestSearchMap_Query(t *testing.T) {
+	core.Register("query-adp", func() core.Adapter { return &QueryAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "web-prod.json"),
+		`{"adapter":"query-adp","labels":{"env":"prod","tier":"web"},"spec":{"region":"eu"}}`)
+	writeFile(t, filepath.Join(dir, "web-canary.json"),
+		`{"adapter":"query-adp","labels":{"env":"prod","tier":"canary"},"spec":{"region":"us"}}`)
+	writeFile(t, filepath.Join(dir, "web-dev.json"),
+		`{"adapter":"query-adp","labels":{"env":"dev"},"spec":{"region":"eu"}}`)
+	writeFile(t, filepath.Join(dir, "other.json"), `{"adapter":"other","labels":{"env":"prod"}}`)
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	names := func(metas []*core.MetaHeader) string {
+		var out []string
+		for _, m := range metas {
+			out = append(out, m.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for query, want := range map[string]string{
+		"adapter=query-adp,env=prod":         "web-canary,web-prod",
+		"env=prod,tier!=canary":              "other,web-prod",
+		"adapter=QUERY-ADP,spec.region=eu":   "web-dev,web-prod",
+		"labels.tier=web":                    "web-prod",
+		"adapter=query-adp,labels.tier!=web": "web-canary,web-dev",
+		"name=other":                         "other",
+	} {
+		metas, err := sm.Query(query)
+		if err != nil {
+			t.Fatalf("Query(%q): %v", query, err)
+		}
+		if got := names(metas); got != want {
+			t.Errorf("Query(%q) = %s, want %s", query, got, want)
+		}
+	}
+	if _, err := sm.Query("env"); err == nil {
+		t.Errorf("Query(env): expected error")
+	}
+
+	// The parsed configs are cached until the index changes.
+	writeFile(t, filepath.Join(dir, "web-dev.json"), `{"adapter":"query-adp","labels":{"env":"prod"}}`)
+	if metas, _ := sm.Query("env=dev"); names(metas) != "web-dev" {
+		t.Fatalf("cached Query(env=dev) = %s", names(metas))
+	}
+	if err := sm.Reindex(); err != nil {
+		t.Fatalf("Reindex: %v", err)
+	}
+	if metas, _ := sm.Query("env=dev"); len(metas) != 0 {
+		t.Fatalf("Query(env=dev) after Reindex = %s", names(metas))
+	}
+
+	adps, err := core.LoadAllAdapters[*QueryAdp]("query-adp", "tier=canary")
+	if err != nil {
+		t.Fatalf("LoadAllAdapters: %v", err)
+	}
+	if len(adps) != 1 || adps[0].Name != "web-canary" || adps[0].Spec.Region != "us" {
+		t.Fatalf("LoadAllAdapters = %+v", adps)
+	}
+	// Without an adapter ID every config is built with its own adapter.
+	if adps, err = core.LoadAllAdapters[*QueryAdp]("", "env=prod"); err != nil || len(adps) != 3 {
+		t.Fatalf("LoadAllAdapters(\"\") = %d adapters, %v, want 3", len(adps), err)
+	}
+
+	// Results are copies; changing them leaves the cache alone.
+	metas, _ := sm.Query("name=web-prod")
+	metas[0].Labels["env"] = "changed"
+	metas[0].RawSpec[2] = 'X'
+	if metas, _ := sm.Query("name=web-prod"); metas[0].Labels["env"] != "prod" || string(metas[0].RawSpec) != `{"region":"eu"}` {
+		t.Fatalf("cached config changed through a query result: %v %s", metas[0].Labels, metas[0].RawSpec)
+	}
+}
+
+func TestSearchMap_LoadAllSeesChanges(t *testing.T) {
+	id := runID("fresh-adp")
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "fresh.json")
+	writeFile(t, cfg, fmt.Sprintf(`{"adapter":%q,"api_version":"v1","spec":{"region":"eu"}}`, id))
+	sm, err := core.SetDefaultSearchPath(dir)
+	if err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	region := func(metas []*core.MetaHeader, err error) string {
+		t.Helper()
+		if err != nil || len(metas) != 1 {
+			t.Fatalf("metas = %v, %v; want one", metas, err)
+		}
+		return string(metas[0].RawSpec)
+	}
+	if got := region(sm.Query("adapter=" + id)); !strings.Contains(got, `"eu"`) {
+		t.Fatalf("spec = %s, want eu", got)
+	}
+
+	// Edits on disk show up in LoadAll without a re-index.
+	writeFile(t, cfg, fmt.Sprintf(`{"adapter":%q,"api_version":"v1","spec":{"region":"us"}}`, id))
+	if got := region(sm.LoadAll(id)); !strings.Contains(got, `"us"`) {
+		t.Fatalf("LoadAll spec = %s, want us", got)
+	}
+
+	// A migration registered later applies to queries too.
+	core.RegisterMigration(id, "v1", "v2", func(spec json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(`{"region":"migrated"}`), nil
+	})
+	if got := region(sm.Query("adapter=" + id)); !strings.Contains(got, `"migrated"`) {
+		t.Fatalf("Query spec = %s, want the migrated one", got)
+	}
+}
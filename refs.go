@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Reference keys in specs. A reference is an object with the key as its only
+// member:
+//
+//	"bucket": {"$ref": "storage/main#/spec/bucket"}
+//	"endpoint": {"$output": "storage/main#/endpoint"}
+//
+// $ref reads a value from another config when it is loaded. $output reads a
+// value from the outputs another item's adapter saved with CollectOutputs, or
+// builds that adapter and reads its Outputs when they don't hold it.
+const (
+	refKey    = "$ref"
+	outputKey = "$output"
+)
+
+// resolveRefs returns copies of the metas with every reference under key in
+// their spec replaced by fn(ref).
+func resolveRefs(key string, fn func(ref string) (any, error), metas ...*MetaHeader) ([]*MetaHeader, error) {
+	out := make([]*MetaHeader, len(metas))
+	for i, m := range metas {
+		if m == nil || !bytes.Contains(m.RawSpec, []byte(key)) {
+			out[i] = m
+			continue
+		}
+		v, err := decodeJSON(m.RawSpec)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s spec: %w", m.Name, err)
+		}
+		v, err = replaceRefs(v, key, fn)
+		if err != nil {
+			return nil, fmt.Errorf("%s spec: %w", m.Name, err)
+		}
+		spec, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		cp := *m
+		cp.RawSpec = spec
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+func replaceRefs(v any, key string, fn func(ref string) (any, error)) (any, error) {
+	switch t := v.(type) {
+	case map[string]any:
+		if ref, ok := t[key]; ok && len(t) == 1 {
+			s, ok := ref.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string, got %T", key, ref)
+			}
+			return fn(s)
+		}
+		for k, child := range t {
+			resolved, err := replaceRefs(child, key, fn)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = resolved
+		}
+	case []any:
+		for i, child := range t {
+			resolved, err := replaceRefs(child, key, fn)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = resolved
+		}
+	}
+	return v, nil
+}
+
+// walkRefs calls fn with the pointer and ref of every reference under key in
+// v, a decoded spec.
+func walkRefs(v any, ptr, key string, fn func(ptr, ref string) error) error {
+	switch t := v.(type) {
+	case map[string]any:
+		if ref, ok := t[key]; ok && len(t) == 1 {
+			s, ok := ref.(string)
+			if !ok {
+				return fmt.Errorf("%s must be a string, got %T", key, ref)
+			}
+			return fn(ptr, s)
+		}
+		for _, k := range sortedKeys(t) {
+			if err := walkRefs(t[k], ptr+jsonPointer(k), key, fn); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, child := range t {
+			if err := walkRefs(child, ptr+jsonPointer(strconv.Itoa(i)), key, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isReference reports whether v is a $ref, $output or $secret object.
+func isReference(v any) bool {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return false
+	}
+	for k := range m {
+		return k == refKey || k == outputKey || k == secretKey
+	}
+	return false
+}
+
+// splitRef splits "key#/pointer" into the config key and JSON pointer.
+func splitRef(ref string) (key, ptr string, err error) {
+	key, ptr, _ = strings.Cut(ref, "#")
+	if key == "" {
+		return "", "", fmt.Errorf("invalid reference %q: want <config>#<pointer>", ref)
+	}
+	if ptr != "" && !strings.HasPrefix(ptr, "/") {
+		return "", "", fmt.Errorf("invalid reference %q: pointer must start with /", ref)
+	}
+	return key, ptr, nil
+}
+
+// resolveSpecRefs replaces the $ref references in h's spec with values of the
+// configs they point to. stack holds the files being loaded, for cycle
+// detection.
+func (sm *SearchMap) resolveSpecRefs(h *MetaHeader, stack []string) error {
+	resolved, err := resolveRefs(refKey, func(ref string) (any, error) {
+		key, ptr, err := splitRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		target, err := sm.load(key, false, stack)
+		if err != nil {
+			return nil, fmt.Errorf("$ref %q: %w", ref, err)
+		}
+		h.refs = append(h.refs, sourcePaths(target)...)
+
+		doc, err := normalizeJSON(target)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := lookupPointer(doc, ptr)
+		if !ok {
+			return nil, fmt.Errorf("$ref %q: %s not found", ref, ptr)
+		}
+		return v, nil
+	}, h)
+	if err != nil {
+		return err
+	}
+	h.RawSpec = resolved[0].RawSpec
+	return nil
+}
+
+// normalizeJSON round-trips v through JSON so lookupPointer can walk it.
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data)
+}
+
+// refCycle reports a $ref or $output cycle.
+func refCycle(stack []string, next string) error {
+	i := slices.Index(stack, next)
+	chain := append(slices.Clone(stack[i:]), next)
+	return fmt.Errorf("reference cycle: %s", strings.Join(chain, " -> "))
+}
+
+type buildChainKey struct{}
+type outputLookupKey struct{}
+
+// buildChain returns the cache keys of the adapters being built by ctx.
+func buildChain(ctx context.Context) []string {
+	chain, _ := ctx.Value(buildChainKey{}).([]string)
+	return chain
+}
+
+// withBuild marks regKey as being built.
+func withBuild(ctx context.Context, regKey string) context.Context {
+	chain := append(slices.Clone(buildChain(ctx)), regKey)
+	ctx = context.WithValue(ctx, outputLookupKey{}, false)
+	return context.WithValue(ctx, buildChainKey{}, chain)
+}
+
+// isOutputLookup reports whether the adapter is requested for its outputs.
+func isOutputLookup(ctx context.Context) bool {
+	v, _ := ctx.Value(outputLookupKey{}).(bool)
+	return v
+}
+
+// resolveOutput reads the value named by an $output reference from the
+// outputs persisted by CollectOutputs, and only builds the adapter or item
+// when they don't hold it. parentKey is the adapter being built, which is
+// rebuilt whenever the referenced one is.
+func (r *Registry) resolveOutput(ctx context.Context, parentKey, ref string) (any, error) {
+	key, ptr, err := splitRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	adapterID, args, err := r.outputTarget(key)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+
+	outKey, err := r.requestKey(adapterID, args...)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+	saved, err := r.SavedOutputs(outKey)
+	switch {
+	case err == nil:
+		doc, err := normalizeJSON(saved.Outputs)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := lookupPointer(doc, ptr); ok {
+			return v, nil
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+
+	lookup := context.WithValue(ctx, outputLookupKey{}, true)
+	a, err := r.newAdapterWithContext(lookup, parentKey, adapterID, "", args...)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+	o, ok := a.(Outputter)
+	if !ok {
+		return nil, fmt.Errorf("$output %q: adapter %s has no outputs", ref, adapterID)
+	}
+	outputs, err := o.Outputs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+	doc, err := normalizeJSON(outputs)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := lookupPointer(doc, ptr)
+	if !ok {
+		return nil, fmt.Errorf("$output %q: %s not found", ref, ptr)
+	}
+	return v, nil
+}
+
+// outputTarget returns the adapter request for an $output config key: a
+// registered adapter ID, or an item config and its adapter.
+func (r *Registry) outputTarget(key string) (adapterID string, args []string, err error) {
+	if r.IsRegistered(key) {
+		return key, nil, nil
+	}
+	meta, err := r.searchMap.Load(key, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, fmt.Errorf("no adapter or config %q", key)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if meta.Adapter == "" {
+		return "", nil, fmt.Errorf("config %q has no adapter", key)
+	}
+	return meta.Adapter, []string{key}, nil
+}
+
+// checkOutputRef verifies an $output reference without building anything.
+func (r *Registry) checkOutputRef(ref string) (any, error) {
+	key, _, err := splitRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	adapterID, _, err := r.outputTarget(key)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return nil, fmt.Errorf("$output %q: %w", ref, err)
+	}
+	if _, ok := zeroFac().(Outputter); !ok {
+		return nil, fmt.Errorf("$output %q: adapter %s has no outputs", ref, adapterID)
+	}
+	return nil, nil
+}
+
+// omitOutputRef stands in for outputs where adapters must not be built.
+func omitOutputRef(ref string) (any, error) {
+	return nil, nil
+}
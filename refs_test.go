@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+func TestSearchMap_Ref(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "storage", "main.json"), `{"spec":{"bucket":"assets","regions":["eu","us"]}}`)
+	writeFile(t, filepath.Join(dir, "app.json"), `{"spec":{
+		"bucket": {"$ref": "storage/main#/spec/bucket"},
+		"region": {"$ref": "storage/main#/spec/regions/1"}
+	}}`)
+	writeFile(t, filepath.Join(dir, "a.json"), `{"spec":{"x": {"$ref": "b#/spec/x"}}}`)
+	writeFile(t, filepath.Join(dir, "b.json"), `{"spec":{"x": {"$ref": "a#/spec/x"}}}`)
+
+	sm, err := core.NewSearchMap(dir)
+	if err != nil {
+		t.Fatalf("NewSearchMap: %v", err)
+	}
+
+	h, err := sm.Load("app", false)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := string(h.RawSpec); got != `{"bucket":"assets","region":"us"}` {
+		t.Fatalf("spec = %s", got)
+	}
+
+	if _, err := sm.Load("a", false); err == nil || !strings.Contains(err.Error(), "reference cycle") {
+		t.Fatalf("Load(a) err = %v, want reference cycle", err)
+	}
+}
+
+// StorageAdp exposes the bucket it "created".
+type StorageAdp struct {
+	Spec struct {
+		Bucket string `json:"bucket"`
+	}
+}
+
+func (a *StorageAdp) ConfigPtr() any { return &a.Spec }
+
+func (a *StorageAdp) Outputs(ctx context.Context) (map[string]any, error) {
+	return map[string]any{"endpoint": "s3://" + a.Spec.Bucket}, nil
+}
+
+// ConsumerAdp reads the endpoint of the storage adapter.
+type ConsumerAdp struct {
+	Spec struct {
+		Endpoint string `json:"endpoint"`
+	}
+}
+
+func (a *ConsumerAdp) ConfigPtr() any { return &a.Spec }
+
+func TestRegistry_OutputRef(t *testing.T) {
+	core.Register("ref-storage", func() core.Adapter { return &StorageAdp{} })
+	core.Register("ref-consumer", func() core.Adapter { return &ConsumerAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ref-storage.json"), `{"spec":{"bucket":"assets"}}`)
+	writeFile(t, filepath.Join(dir, "ref-consumer.json"), `{"spec":{"endpoint":{"$output":"ref-storage#/endpoint"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a, err := core.NewAdapterAs[*ConsumerAdp]("ref-consumer")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if got := a.Spec.Endpoint; got != "s3://assets" {
+		t.Fatalf("Endpoint = %q, want s3://assets", got)
+	}
+}
@@ -2,11 +2,12 @@ package core
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -18,15 +19,32 @@ type Adapter any
 type ZeroFactory func() Adapter
 
 type Registry struct {
-	mu        sync.RWMutex
-	factories map[string]ZeroFactory
-	adapters  map[string]Adapter
-	searchMap *SearchMap
+	mu         sync.RWMutex
+	factories  map[string]ZeroFactory
+	adapters   map[string]Adapter
+	entries    map[string]*adapterEntry
+	searchMap  *SearchMap
+	strict     bool
+	migrations *Migrations
+	stateStore StateStore
+	outputsMu  sync.Mutex // serializes writes to the outputs file
+}
+
+// adapterEntry records how a cached adapter was requested, which config files
+// it was built from and which cached adapters it was injected into.
+type adapterEntry struct {
+	adapterID  string
+	args       []string
+	workDir    string // default work dir passed down by the parent
+	files      []string
+	dependents map[string]bool // cache keys of parents
 }
 
 var defaultRegistry = &Registry{
-	factories: make(map[string]ZeroFactory),
-	adapters:  make(map[string]Adapter),
+	factories:  make(map[string]ZeroFactory),
+	adapters:   make(map[string]Adapter),
+	entries:    make(map[string]*adapterEntry),
+	migrations: NewMigrations(),
 }
 
 // DefaultRegistry returns the package-global registry used by the helper funcs.
@@ -37,23 +55,26 @@ func DefaultRegistry() *Registry {
 // SetSearchMap sets the SearchMap used by this registry.
 // In typical CLI usage it's set once at startup; we don't worry about races here.
 func (r *Registry) SetSearchMap(sm *SearchMap) {
+	if sm != nil {
+		sm.SetMigrations(r.Migrations())
+	}
 	r.searchMap = sm
 }
 
-// SetSearchPath constructs a SearchMap rooted at the given path and installs it
-// into this registry. It returns the created SearchMap.
-func (r *Registry) SetSearchPath(root string) (*SearchMap, error) {
-	sm, err := NewSearchMap(root)
+// SetSearchPath constructs a SearchMap rooted at the given paths (highest
+// precedence first) and installs it into this registry. It returns the created SearchMap.
+func (r *Registry) SetSearchPath(roots ...string) (*SearchMap, error) {
+	sm, err := NewSearchMap(roots...)
 	if err != nil {
 		return nil, err
 	}
-	r.searchMap = sm
+	r.SetSearchMap(sm)
 	return sm, nil
 }
 
 // Convenience: configure the default registry's search path.
-func SetDefaultSearchPath(root string) (*SearchMap, error) {
-	return defaultRegistry.SetSearchPath(root)
+func SetDefaultSearchPath(roots ...string) (*SearchMap, error) {
+	return defaultRegistry.SetSearchPath(roots...)
 }
 
 // (Optional) Lower-level convenience if you already built a SearchMap yourself.
@@ -106,28 +127,79 @@ func keyGen(adapter Adapter, adapterID string, item *MetaHeader, contextPath str
 	return fmt.Sprintf("%s__%016x", key, h.Sum64())
 }
 
-func applyConfig(adapter Adapter, adapterID string, meta, itemMeta *MetaHeader) error {
+// applyConfig applies `default` tags and decodes the adapter-level spec, then
+// overlays the item-level spec.
+func applyConfig(adapter Adapter, adapterID string, meta, itemMeta *MetaHeader, strict bool) error {
 	// Adapter-level config.
-	if meta != nil && len(meta.RawSpec) > 0 {
-		if configurable, ok := adapter.(Configurable); ok {
+	var configPtr any
+	if configurable, ok := adapter.(Configurable); ok {
+		ptr := configurable.ConfigPtr()
+		configPtr = ptr
+		if err := applyDefaults(ptr); err != nil {
+			return fmt.Errorf("defaults for %s spec: %w", adapterID, err)
+		}
+		if meta != nil && len(meta.RawSpec) > 0 {
 			Log().Debugf("setting config for adapter %s", adapterID)
-			if err := json.Unmarshal(meta.RawSpec, configurable.ConfigPtr()); err != nil {
+			if err := decodeSpec(meta.RawSpec, ptr, strict); err != nil {
 				return fmt.Errorf("decode %s spec: %w", adapterID, err)
 			}
 		}
 	}
 	// Item-level config overlay.
-	if itemMeta != nil && len(itemMeta.RawSpec) > 0 {
+	if itemMeta != nil {
 		if itemConfigurable, ok := adapter.(ItemConfigurable); ok {
-			Log().Debugf("setting item config for adapter %s", adapterID)
-			if err := json.Unmarshal(itemMeta.RawSpec, itemConfigurable.ItemConfigPtr(itemMeta.Name)); err != nil {
-				return fmt.Errorf("decode %s spec: %w", itemMeta.Name, err)
+			ptr := itemConfigurable.ItemConfigPtr(itemMeta.Name)
+			// A shared struct already has its defaults, under the adapter
+			// config; applying them again would undo explicit zero values.
+			if configPtr == nil || !samePointer(configPtr, ptr) {
+				if err := applyDefaults(ptr); err != nil {
+					return fmt.Errorf("defaults for %s spec: %w", itemMeta.Name, err)
+				}
+			}
+			if len(itemMeta.RawSpec) > 0 {
+				Log().Debugf("setting item config for adapter %s", adapterID)
+				if err := decodeSpec(itemMeta.RawSpec, ptr, strict); err != nil {
+					return fmt.Errorf("decode %s spec: %w", itemMeta.Name, err)
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// validateConfig runs the `validate` tags of the adapter and item config
+// structs and reports all violations at once.
+func validateConfig(adapter Adapter, itemMeta *MetaHeader) error {
+	var ptrs []any
+	if configurable, ok := adapter.(Configurable); ok {
+		ptrs = append(ptrs, configurable.ConfigPtr())
+	}
+	if itemConfigurable, ok := adapter.(ItemConfigurable); ok && itemMeta != nil {
+		ptr := itemConfigurable.ItemConfigPtr(itemMeta.Name)
+		if len(ptrs) == 0 || !samePointer(ptrs[0], ptr) {
+			ptrs = append(ptrs, ptr)
+		}
+	}
+
+	var violations []Violation
+	for _, ptr := range ptrs {
+		var ve *ValidationError
+		if err := validateSpec(ptr, "/spec"); errors.As(err, &ve) {
+			violations = append(violations, ve.Violations...)
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func samePointer(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Ptr && vb.Kind() == reflect.Ptr &&
+		va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
 func resolveWorkDir(defaultWorkDir string, metas ...*MetaHeader) string {
 	newWorkDir := defaultWorkDir
 	for _, m := range metas {
@@ -156,15 +228,27 @@ func debugAdapterInfo(zero Adapter, adapterID string, args ...string) {
 	if _, ok := zero.(Depender); ok {
 		implements = append(implements, "Depender")
 	}
+	if _, ok := zero.(StrictConfigurable); ok {
+		implements = append(implements, "StrictConfigurable")
+	}
 	Log().Debugf("request adapter %s (%s) %v\n", adapterID, strings.Join(implements, ","), args)
 }
 
+// NewAdapter constructs or reuses an adapter instance.
 func (r *Registry) NewAdapter(adapterID string, args ...string) (Adapter, error) {
-	return r.newAdapterWithContext(adapterID, "", args...)
+	return r.newAdapter(context.Background(), adapterID, args...)
+}
+
+// newAdapter is NewAdapter under ctx, which reaches the secret providers
+// resolving the adapter's spec.
+func (r *Registry) newAdapter(ctx context.Context, adapterID string, args ...string) (Adapter, error) {
+	return r.newAdapterWithContext(ctx, "", adapterID, "", args...)
 }
 
 // NewAdapter constructs or reuses an adapter instance in this registry.
-func (r *Registry) newAdapterWithContext(adapterID string, defaultWorkDir string, args ...string) (Adapter, error) {
+// parentKey is the cache key of the adapter requesting it as a dependency, if any.
+// ctx carries the adapters being built, for $output cycle detection.
+func (r *Registry) newAdapterWithContext(ctx context.Context, parentKey, adapterID string, defaultWorkDir string, args ...string) (Adapter, error) {
 	if r.searchMap == nil {
 		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
 	}
@@ -178,22 +262,9 @@ func (r *Registry) newAdapterWithContext(adapterID string, defaultWorkDir string
 
 	debugAdapterInfo(zero, adapterID, args...)
 
-	var meta *MetaHeader
-	var itemMeta *MetaHeader
-
-	// Adapter-level config (optional).
-	meta, err = r.searchMap.Load(adapterID, true)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("failed reading config for adapter %s: %v", adapterID, err)
-	}
-
-	// Item-level config (optional, if adapter supports it and config arg provided).
-	if _, isItemConfigurable := zero.(ItemConfigurable); isItemConfigurable && len(args) > 0 {
-		configPath := args[0]
-		itemMeta, err = r.searchMap.Load(configPath, true)
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("failed reading item config: %s for adapter %s: %v", configPath, adapterID, err)
-		}
+	meta, itemMeta, err := r.loadMetas(zero, adapterID, args...)
+	if err != nil {
+		return nil, err
 	}
 
 	resolvedWorkDir := resolveWorkDir(defaultWorkDir, meta, itemMeta)
@@ -201,10 +272,17 @@ func (r *Registry) newAdapterWithContext(adapterID string, defaultWorkDir string
 	regKey := keyGen(zero, adapterID, itemMeta, resolvedWorkDir)
 
 	// Reuse existing adapter if present.
-	r.mu.RLock()
+	r.mu.Lock()
 	existing, ok := r.adapters[regKey]
-	r.mu.RUnlock()
 	if ok {
+		r.addDependent(regKey, parentKey)
+	}
+	r.mu.Unlock()
+	if ok {
+		// An adapter still being built has no outputs yet.
+		if chain := buildChain(ctx); isOutputLookup(ctx) && slices.Contains(chain, regKey) {
+			return nil, refCycle(chain, regKey)
+		}
 		Log().Debugf("reusing adapter: %s %v\n", adapterID, args)
 		return existing, nil
 	}
@@ -215,12 +293,57 @@ func (r *Registry) newAdapterWithContext(adapterID string, defaultWorkDir string
 
 	r.mu.Lock()
 	r.adapters[regKey] = adapter
+	r.entries[regKey] = &adapterEntry{
+		adapterID: strings.ToLower(adapterID),
+		args:      slices.Clone(args),
+		workDir:   defaultWorkDir,
+		files:     sourcePaths(meta, itemMeta),
+	}
+	r.addDependent(regKey, parentKey)
 	r.mu.Unlock()
 
-	// Configs
-	if err := applyConfig(adapter, adapterID, meta, itemMeta); err != nil {
+	if err := r.buildAdapter(withBuild(ctx, regKey), adapter, regKey, adapterID, resolvedWorkDir, meta, itemMeta); err != nil {
+		// Don't leave a half-built adapter behind for later requests.
+		r.mu.Lock()
+		delete(r.adapters, regKey)
+		delete(r.entries, regKey)
+		r.mu.Unlock()
 		return nil, err
 	}
+	return adapter, nil
+}
+
+// buildAdapter configures, wires and hydrates a freshly cached adapter.
+func (r *Registry) buildAdapter(ctx context.Context, adapter Adapter, regKey, adapterID, resolvedWorkDir string, meta, itemMeta *MetaHeader) error {
+	// Secrets referenced from specs.
+	resolved, err := resolveSecretRefs(func(ref string) (string, error) {
+		return r.resolveSecret(ctx, ref)
+	}, meta, itemMeta)
+	if err != nil {
+		return fmt.Errorf("resolving secrets for %s: %w", adapterID, err)
+	}
+
+	// Outputs of other adapters, which are built first.
+	resolved, err = resolveRefs(outputKey, func(ref string) (any, error) {
+		return r.resolveOutput(ctx, regKey, ref)
+	}, resolved...)
+	if err != nil {
+		return fmt.Errorf("resolving outputs for %s: %w", adapterID, err)
+	}
+
+	// Configs
+	strict := r.isStrict(adapter)
+	if strict {
+		if err := r.checkUnknownFields(adapter, meta, itemMeta); err != nil {
+			return fmt.Errorf("config for adapter %s: %w", adapterID, err)
+		}
+	}
+	if err := applyConfig(adapter, adapterID, resolved[0], resolved[1], strict); err != nil {
+		return err
+	}
+	if err := validateConfig(adapter, itemMeta); err != nil {
+		return fmt.Errorf("validating adapter %s: %w", adapterID, err)
+	}
 
 	// Set the working directory (allowing dependency override logic).
 	if wdSetter, ok := adapter.(WorkDirSettable); ok && resolvedWorkDir != "" {
@@ -229,35 +352,100 @@ func (r *Registry) newAdapterWithContext(adapterID string, defaultWorkDir string
 	}
 
 	// Dependencies.
-	if err := applyDeps(adapter, resolvedWorkDir, meta); err != nil {
-		return nil, fmt.Errorf("dependency resolution for %s: %w", adapterID, err)
+	newDep := func(depID, workDir string, depArgs ...string) (Adapter, error) {
+		return r.newAdapterWithContext(ctx, regKey, depID, workDir, depArgs...)
 	}
-	if err := applyDeps(adapter, resolvedWorkDir, itemMeta); err != nil {
-		return nil, fmt.Errorf("dependency resolution for %s: %w", adapterID, err)
+	if err := applyDeps(adapter, resolvedWorkDir, r.searchMap.PathMapper().ToHost, newDep, meta, itemMeta); err != nil {
+		return fmt.Errorf("dependency resolution for %s: %w", adapterID, err)
 	}
 
 	// Required dependency validation.
 	if err := validateRequiredDeps(adapter); err != nil {
-		return nil, fmt.Errorf("validating adapter %s: %w", adapterID, err)
+		return fmt.Errorf("validating adapter %s: %w", adapterID, err)
+	}
+
+	// State of the item, for idempotent lifecycle actions.
+	if sa, ok := adapter.(StateAware); ok {
+		store, err := r.StateStore()
+		if err != nil {
+			return fmt.Errorf("state for adapter %s: %w", adapterID, err)
+		}
+		sa.SetState(NewState(store, stateKeyFor(adapterID, itemMeta)))
 	}
 
 	// Hydration hook.
 	if hydrater, ok := adapter.(Hydrater); ok {
 		Log().Debugf("hydrating adapter: %s\n", adapterID)
 		if err := hydrater.Hydrate(context.Background()); err != nil {
-			return nil, fmt.Errorf("hydrating adapter %s: %v", adapterID, err)
+			return fmt.Errorf("hydrating adapter %s: %v", adapterID, err)
 		}
 	}
 
-	return adapter, nil
+	return nil
+}
+
+// addDependent records that the adapter at key was injected into parentKey.
+// Callers must hold r.mu.
+func (r *Registry) addDependent(key, parentKey string) {
+	e, ok := r.entries[key]
+	if !ok || parentKey == "" {
+		return
+	}
+	if e.dependents == nil {
+		e.dependents = make(map[string]bool)
+	}
+	e.dependents[parentKey] = true
 }
 
-// loadAllMetas is a small helper to retrieve all MetaHeaders for an adapter ID.
-func (r *Registry) loadAllMetas(adapterID string) ([]*MetaHeader, error) {
+// sourcePaths lists the config files the metas were loaded from.
+func sourcePaths(metas ...*MetaHeader) []string {
+	var out []string
+	for _, m := range metas {
+		if m == nil {
+			continue
+		}
+		for _, src := range m.Sources {
+			out = append(out, src.Path)
+		}
+		out = append(out, m.refs...)
+	}
+	return out
+}
+
+// loadMetas loads the adapter-level and item-level configs for a request.
+// Both are optional and may be nil.
+func (r *Registry) loadMetas(zero Adapter, adapterID string, args ...string) (meta, itemMeta *MetaHeader, err error) {
+	// Adapter-level config (optional).
+	meta, err = r.searchMap.Load(adapterID, true)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed reading config for adapter %s: %v", adapterID, err)
+	}
+
+	// Item-level config (optional, if adapter supports it and config arg provided).
+	if _, isItemConfigurable := zero.(ItemConfigurable); isItemConfigurable && len(args) > 0 {
+		configPath := args[0]
+		itemMeta, err = r.searchMap.Load(configPath, true)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("failed reading item config: %s for adapter %s: %v", configPath, adapterID, err)
+		}
+	}
+	return meta, itemMeta, nil
+}
+
+// loadAllMetas is a small helper to retrieve all MetaHeaders for an adapter ID
+// that match the selectors.
+func (r *Registry) loadAllMetas(adapterID string, selectors ...string) ([]*MetaHeader, error) {
 	if r.searchMap == nil {
 		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
 	}
-	return r.searchMap.LoadAll(adapterID)
+	sels, err := parseSelectors(strings.Join(selectors, ","))
+	if err != nil {
+		return nil, err
+	}
+	if adapterID != "" {
+		sels = append([]selector{{field: "adapter", value: adapterID}}, sels...)
+	}
+	return r.searchMap.query(sels)
 }
 
 // --- Generic helpers (functions, not methods) ---
@@ -283,15 +471,24 @@ func NewAdapterAsFrom[T any](r *Registry, adapterID string, args ...string) (T,
 
 // LoadAllAdaptersFrom loads all configured items for adapterID from the given registry
 // and returns them as []T, skipping items that fail type assertion or construction.
-func LoadAllAdaptersFrom[T any](r *Registry, adapterID string) ([]T, error) {
-	metas, err := r.loadAllMetas(adapterID)
+// Optional selectors (see SearchMap.Query) limit which items are built:
+//
+//	core.LoadAllAdaptersFrom[Deployer](r, "k8s", "env=prod")
+//
+// An empty adapterID loads the configs of every adapter, each with its own.
+func LoadAllAdaptersFrom[T any](r *Registry, adapterID string, selectors ...string) ([]T, error) {
+	metas, err := r.loadAllMetas(adapterID, selectors...)
 	if err != nil {
 		return nil, err
 	}
 
 	var out []T
 	for _, meta := range metas {
-		a, err := NewAdapterAsFrom[T](r, adapterID, meta.Name)
+		id := adapterID
+		if id == "" {
+			id = migrationAdapter(meta)
+		}
+		a, err := NewAdapterAsFrom[T](r, id, meta.Name)
 		if err != nil {
 			Log().Errorf("error: %v\n", err)
 			continue
@@ -354,16 +551,12 @@ func NewAdapter(adapterID string, args ...string) (Adapter, error) {
 	return defaultRegistry.NewAdapter(adapterID, args...)
 }
 
-func newAdapterWithContext(adapterID string, defaultContext string, args ...string) (Adapter, error) {
-	return defaultRegistry.newAdapterWithContext(adapterID, defaultContext, args...)
-}
-
 // NewAdapterAs constructs an adapter from the default registry and asserts it implements T.
 func NewAdapterAs[T any](adapterID string, args ...string) (T, error) {
 	return NewAdapterAsFrom[T](defaultRegistry, adapterID, args...)
 }
 
 // LoadAllAdapters loads all configured items for adapterID from the default registry.
-func LoadAllAdapters[T any](adapterID string) ([]T, error) {
-	return LoadAllAdaptersFrom[T](defaultRegistry, adapterID)
+func LoadAllAdapters[T any](adapterID string, selectors ...string) ([]T, error) {
+	return LoadAllAdaptersFrom[T](defaultRegistry, adapterID, selectors...)
 }
@@ -0,0 +1,460 @@
+// Package render prints ListerOf and FilterOf results consistently: aligned
+// tables, JSON, YAML, CSV or a Go template per item, with field selection
+// and sorting.
+//
+// Table and CSV columns come from struct fields tagged with col:
+//
+//	type Bucket struct {
+//		Name    string    `json:"name" col:"NAME"`
+//		Region  string    `json:"region" col:"REGION"`
+//		Created time.Time `json:"created" col:"CREATED"`
+//		ARN     string    `json:"arn"` // JSON and YAML only
+//	}
+//
+// Without any col tags every exported field is a column, headed by its JSON
+// name in upper case. Fields of embedded structs are promoted as in
+// encoding/json. Items that are not structs render as a single VALUE column.
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+	"github.com/bartdeboer/go-core/internal/yaml"
+)
+
+// Output formats.
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatTemplate = "template"
+)
+
+// Options controls how items are written.
+type Options struct {
+	// Format is one of the Format constants; empty means FormatTable.
+	// "template=<text>" sets the format and Template at once.
+	Format string
+	// Template is executed for each item with FormatTemplate.
+	Template string
+	// Fields selects and orders the columns, or the keys of JSON and YAML
+	// objects. Fields match a column header, JSON name or Go field name,
+	// ignoring case. Empty means all columns, or whole items in JSON and YAML.
+	Fields []string
+	// SortBy sorts the items by a field, matched like Fields; a leading "-"
+	// sorts descending.
+	SortBy string
+	// NoHeaders omits the header row of tables and CSV.
+	NoHeaders bool
+}
+
+// column is a field of T that can be rendered.
+type column struct {
+	header string // table and CSV header
+	key    string // JSON name
+	name   string // Go field name
+	index  []int  // nil for non-struct items
+	tagged bool
+}
+
+func (c column) matches(field string) bool {
+	return strings.EqualFold(field, c.header) || strings.EqualFold(field, c.key) || strings.EqualFold(field, c.name)
+}
+
+// Write renders items to w.
+func Write[T any](w io.Writer, items []T, opts Options) error {
+	format, tmpl := opts.Format, opts.Template
+	if rest, ok := strings.CutPrefix(format, FormatTemplate+"="); ok {
+		format, tmpl = FormatTemplate, rest
+	}
+	format = strings.ToLower(format)
+
+	all := columnsOf(reflect.TypeFor[T]())
+	values := make([]reflect.Value, len(items))
+	for i := range items {
+		values[i] = reflect.ValueOf(&items[i]).Elem()
+	}
+	if opts.SortBy != "" {
+		if err := sortValues(values, all, opts.SortBy); err != nil {
+			return err
+		}
+	}
+
+	cols, err := selectColumns(all, opts.Fields, format == FormatTable || format == FormatCSV || format == "")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatTable, "":
+		return writeTable(w, values, cols, opts.NoHeaders)
+	case FormatCSV:
+		return writeCSV(w, values, cols, opts.NoHeaders)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(project(values, cols, len(opts.Fields) > 0))
+	case FormatYAML:
+		return yaml.Encode(w, project(values, cols, len(opts.Fields) > 0))
+	case FormatTemplate:
+		return writeTemplate(w, values, tmpl)
+	}
+	return fmt.Errorf("unknown format %q: want table, json, yaml, csv or template", opts.Format)
+}
+
+// List lists with l and renders the result.
+func List[T any](ctx context.Context, w io.Writer, l core.ListerOf[T], opts Options) error {
+	items, err := l.List(ctx)
+	if err != nil {
+		return err
+	}
+	return Write(w, items, opts)
+}
+
+// Filter filters with f and renders the result.
+func Filter[T any](ctx context.Context, w io.Writer, f core.FilterOf[T], filter string, opts Options) error {
+	items, err := f.Filter(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return Write(w, items, opts)
+}
+
+// columnsOf returns the columns of items of type t.
+func columnsOf(t reflect.Type) []column {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() {
+		return []column{{header: "VALUE", key: "value", name: "Value"}}
+	}
+
+	cols, tagged := fieldColumns(t, nil)
+	cols = unshadowed(cols)
+	// Tagged structs list their columns explicitly; the rest stay selectable.
+	if tagged {
+		sort.SliceStable(cols, func(i, j int) bool { return cols[i].tagged && !cols[j].tagged })
+	}
+	return cols
+}
+
+// fieldColumns returns the columns of struct type t, whose fields are at
+// index in the item, promoting the fields of embedded structs like
+// encoding/json does.
+func fieldColumns(t reflect.Type, index []int) (cols []column, tagged bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		idx := append(slices.Clone(index), sf.Index...)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if ft := derefType(sf.Type); sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != reflect.TypeFor[time.Time]() {
+			sub, subTagged := fieldColumns(ft, idx)
+			cols, tagged = append(cols, sub...), tagged || subTagged
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Name
+		if name == "-" {
+			key = ""
+		} else if name != "" {
+			key = name
+		}
+		col := column{key: key, name: sf.Name, index: idx}
+		switch tag := sf.Tag.Get("col"); tag {
+		case "-":
+			continue
+		case "":
+			col.header = strings.ToUpper(key)
+		default:
+			col.header, col.tagged, tagged = tag, true, true
+		}
+		if key == "" && !col.tagged {
+			continue
+		}
+		cols = append(cols, col)
+	}
+	return cols, tagged
+}
+
+// unshadowed drops promoted columns hidden by a shallower one with the same
+// key.
+func unshadowed(cols []column) []column {
+	depth := make(map[string]int)
+	for _, c := range cols {
+		if d, ok := depth[c.key]; !ok || len(c.index) < d {
+			depth[c.key] = len(c.index)
+		}
+	}
+	return slices.DeleteFunc(cols, func(c column) bool { return len(c.index) > depth[c.key] })
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// selectColumns applies the field selection. Without one, tables show the
+// tagged columns of tagged structs.
+func selectColumns(all []column, fields []string, table bool) ([]column, error) {
+	if len(fields) == 0 {
+		if !table {
+			return all, nil
+		}
+		var out []column
+		for _, c := range all {
+			if c.tagged {
+				out = append(out, c)
+			}
+		}
+		if len(out) == 0 {
+			return all, nil
+		}
+		return out, nil
+	}
+
+	out := make([]column, 0, len(fields))
+	for _, f := range fields {
+		c, ok := findColumn(all, strings.TrimSpace(f))
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q: want one of %s", f, strings.Join(headers(all), ", "))
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func findColumn(cols []column, field string) (column, bool) {
+	for _, c := range cols {
+		if c.matches(field) {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+func headers(cols []column) []string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = c.header
+	}
+	return out
+}
+
+// field returns the column's value in item, or an invalid Value through a
+// nil pointer.
+func (c column) field(item reflect.Value) reflect.Value {
+	for item.Kind() == reflect.Ptr {
+		if item.IsNil() {
+			return reflect.Value{}
+		}
+		item = item.Elem()
+	}
+	if c.index == nil {
+		return item
+	}
+	v, err := item.FieldByIndexErr(c.index)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return v
+}
+
+// cell formats a value for tables and CSV.
+func cell(v reflect.Value) string {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "-"
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "-"
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if x.IsZero() {
+			return "-"
+		}
+		return x.Format(time.RFC3339)
+	case fmt.Stringer:
+		return x.String()
+	}
+	// Stringers with pointer receivers, reached through a pointer.
+	if v.CanAddr() {
+		if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = cell(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	case reflect.Map, reflect.Struct:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func writeTable(w io.Writer, values []reflect.Value, cols []column, noHeaders bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !noHeaders {
+		if _, err := fmt.Fprintln(tw, strings.Join(headers(cols), "\t")); err != nil {
+			return err
+		}
+	}
+	for _, item := range values {
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			// Tabs and newlines would break the alignment.
+			row[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell(c.field(item)))
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, values []reflect.Value, cols []column, noHeaders bool) error {
+	cw := csv.NewWriter(w)
+	if !noHeaders {
+		if err := cw.Write(headers(cols)); err != nil {
+			return err
+		}
+	}
+	for _, item := range values {
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			row[i] = cell(c.field(item))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// project returns the items for JSON and YAML: whole, or as objects of the
+// selected fields.
+func project(values []reflect.Value, cols []column, selected bool) []any {
+	out := make([]any, len(values))
+	for i, item := range values {
+		if !selected {
+			out[i] = item.Interface()
+			continue
+		}
+		obj := make(yaml.Object, 0, len(cols))
+		for _, c := range cols {
+			var v any
+			if f := c.field(item); f.IsValid() {
+				v = f.Interface()
+			}
+			key := c.key
+			if key == "" {
+				key = c.name
+			}
+			obj = append(obj, yaml.Member{Key: key, Value: v})
+		}
+		out[i] = obj
+	}
+	return out
+}
+
+func writeTemplate(w io.Writer, values []reflect.Value, text string) error {
+	if text == "" {
+		return fmt.Errorf("template format needs a template")
+	}
+	tmpl, err := template.New("item").Parse(text)
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	for _, item := range values {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, item.Interface()); err != nil {
+			return err
+		}
+		// One item per line unless the template ends lines itself.
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortValues sorts items by the field in sortBy, stably.
+func sortValues(values []reflect.Value, cols []column, sortBy string) error {
+	field, desc := strings.CutPrefix(sortBy, "-")
+	c, ok := findColumn(cols, field)
+	if !ok {
+		return fmt.Errorf("unknown sort field %q: want one of %s", field, strings.Join(headers(cols), ", "))
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := c.field(values[i]), c.field(values[j])
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+	return nil
+}
+
+// less orders numbers numerically, times chronologically and everything
+// else by its cell text. Missing values sort first.
+func less(a, b reflect.Value) bool {
+	a, b = deref(a), deref(b)
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		if tb, ok := b.Interface().(time.Time); ok {
+			return ta.Before(tb)
+		}
+	}
+	switch {
+	case a.CanInt() && b.CanInt():
+		return a.Int() < b.Int()
+	case a.CanUint() && b.CanUint():
+		return a.Uint() < b.Uint()
+	case a.CanFloat() && b.CanFloat():
+		return a.Float() < b.Float()
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return cell(a) < cell(b)
+}
+
+func deref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
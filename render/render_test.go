@@ -0,0 +1,170 @@
+package render_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartdeboer/go-core/render"
+)
+
+type bucket struct {
+	Name    string    `json:"name" col:"NAME"`
+	Objects int       `json:"objects" col:"OBJECTS"`
+	Created time.Time `json:"created" col:"CREATED"`
+	ARN     string    `json:"arn"`
+}
+
+var buckets = []bucket{
+	{Name: "media", Objects: 12, Created: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), ARN: "arn:media"},
+	{Name: "assets", Objects: 7, Created: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), ARN: "arn:assets"},
+	{Name: "logs", Objects: 300, ARN: "arn:logs"},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		opts render.Options
+		want string
+	}{
+		{
+			name: "table",
+			opts: render.Options{SortBy: "name"},
+			want: `NAME    OBJECTS  CREATED
+assets  7        2023-01-05T00:00:00Z
+logs    300      -
+media   12       2024-03-01T00:00:00Z
+`,
+		},
+		{
+			name: "numeric sort descending with selected fields",
+			opts: render.Options{SortBy: "-objects", Fields: []string{"name", "arn"}},
+			want: `NAME    ARN
+logs    arn:logs
+media   arn:media
+assets  arn:assets
+`,
+		},
+		{
+			name: "csv",
+			opts: render.Options{Format: "csv", Fields: []string{"Name", "Objects"}, NoHeaders: true},
+			want: "media,12\nassets,7\nlogs,300\n",
+		},
+		{
+			name: "json with selected fields",
+			opts: render.Options{Format: "json", Fields: []string{"objects", "NAME"}, SortBy: "objects"},
+			want: `[
+  {
+    "objects": 7,
+    "name": "assets"
+  },
+  {
+    "objects": 12,
+    "name": "media"
+  },
+  {
+    "objects": 300,
+    "name": "logs"
+  }
+]
+`,
+		},
+		{
+			name: "yaml",
+			opts: render.Options{Format: "yaml", Fields: []string{"name"}},
+			want: "- name: media\n- name: assets\n- name: logs\n",
+		},
+		{
+			name: "template",
+			opts: render.Options{Format: "template={{.Name}}={{.Objects}}", SortBy: "created"},
+			want: "logs=300\nassets=7\nmedia=12\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := render.Write(&buf, buckets, tt.opts); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := render.Write(&buf, buckets, render.Options{Fields: []string{"size"}}); err == nil ||
+		!strings.Contains(err.Error(), "NAME, OBJECTS, CREATED, ARN") {
+		t.Fatalf("unknown field err = %v", err)
+	}
+	if err := render.Write(&buf, buckets, render.Options{Format: "xml"}); err == nil {
+		t.Fatalf("unknown format succeeded")
+	}
+}
+
+type nameLister struct{}
+
+func (nameLister) List(ctx context.Context) ([]string, error) {
+	return []string{"b", "a"}, nil
+}
+
+func TestList(t *testing.T) {
+	var buf bytes.Buffer
+	if err := render.List(context.Background(), &buf, nameLister{}, render.Options{SortBy: "value"}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got := buf.String(); got != "VALUE\na\nb\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+type resource struct {
+	ID   string `json:"id" col:"ID"`
+	Name string `json:"name" col:"RESOURCE"`
+}
+
+// instance embeds resource, shadowing its name.
+type instance struct {
+	resource
+	Name    string     `json:"name" col:"NAME"`
+	Started *time.Time `json:"started" col:"STARTED"`
+}
+
+func TestWrite_EmbeddedAndPointers(t *testing.T) {
+	started, zero := time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC), time.Time{}
+	items := []instance{
+		{resource: resource{ID: "i-1"}, Name: "web", Started: &started},
+		{resource: resource{ID: "i-2"}, Name: "worker", Started: &zero},
+		{resource: resource{ID: "i-3"}, Name: "batch"},
+	}
+	var buf bytes.Buffer
+	if err := render.Write(&buf, items, render.Options{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `ID   NAME    STARTED
+i-1  web     2024-05-02T08:00:00Z
+i-2  worker  -
+i-3  batch   -
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWrite_WriterError(t *testing.T) {
+	for _, format := range []string{"table", "csv", "json", "yaml"} {
+		if err := render.Write(failingWriter{}, buckets, render.Options{Format: format}); err == nil {
+			t.Errorf("Write(%s) to a failing writer succeeded", format)
+		}
+	}
+}
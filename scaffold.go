@@ -0,0 +1,166 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+)
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// Scaffold writes a ready-to-edit config file for adapterID into the search
+// path and returns its path. With an empty name, or a name equal to the
+// adapter ID, it writes the adapter config from ConfigPtr; otherwise it writes
+// the item config name from ItemConfigPtr. Specs have their `default` tags
+// applied, Secret fields become $secret placeholders and every `core` tagged
+// dependency gets an entry; in item configs only those with a known adapter. Existing files are not overwritten.
+func (r *Registry) Scaffold(adapterID, name string) (string, error) {
+	sm := r.searchMap
+	if sm == nil {
+		return "", fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return "", err
+	}
+	zero := zeroFac()
+
+	if name == "" {
+		name = adapterID
+	}
+	name = cleanKey(name)
+	isItem := !strings.EqualFold(path.Base(name), adapterID)
+
+	meta := &MetaHeader{APIVersion: r.Migrations().Current(adapterID)}
+	var ptr any
+	if isItem {
+		ic, ok := zero.(ItemConfigurable)
+		if !ok {
+			return "", fmt.Errorf("adapter %s has no item config", adapterID)
+		}
+		meta.Adapter = adapterID
+		ptr = ic.ItemConfigPtr(path.Base(name))
+	} else if c, ok := zero.(Configurable); ok {
+		ptr = c.ConfigPtr()
+	}
+
+	if ptr != nil {
+		if err := applyDefaults(ptr); err != nil {
+			return "", fmt.Errorf("defaults for %s spec: %w", adapterID, err)
+		}
+		spec, err := scaffoldSpec(ptr)
+		if err != nil {
+			return "", fmt.Errorf("encode %s spec: %w", adapterID, err)
+		}
+		meta.RawSpec = spec
+	}
+
+	meta.Dependencies, err = scaffoldDeps(zero, !isItem)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := sm.saveTarget(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := t.fs.ReadFile(t.path); err == nil {
+		return "", fmt.Errorf("scaffold %s: %w", t.path, os.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := sm.Save(meta, name); err != nil {
+		return "", err
+	}
+	return t.path, nil
+}
+
+// scaffoldSpec marshals a config struct, replacing Secret fields with $secret
+// placeholders since they would otherwise marshal redacted.
+func scaffoldSpec(ptr any) (json.RawMessage, error) {
+	data, err := json.Marshal(ptr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(secretPlaceholders(v, reflect.TypeOf(ptr)))
+}
+
+func secretPlaceholders(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == secretType {
+		return map[string]any{secretKey: "provider:ref"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		for _, f := range jsonFields(t) {
+			if child, ok := obj[f.name]; ok {
+				obj[f.name] = secretPlaceholders(child, f.typ)
+			}
+		}
+	case reflect.Map:
+		if obj, ok := v.(map[string]any); ok {
+			for k, child := range obj {
+				obj[k] = secretPlaceholders(child, t.Elem())
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := v.([]any); ok {
+			for i, child := range arr {
+				arr[i] = secretPlaceholders(child, t.Elem())
+			}
+		}
+	}
+	return v
+}
+
+// scaffoldDeps returns an entry for every `core` tagged field. With
+// placeholders, fields tagged only "required" get an empty adapter to fill
+// in; item configs leave them out, as an empty adapter there would override
+// the one in the adapter config.
+func scaffoldDeps(zero Adapter, placeholders bool) (map[string]DepRef, error) {
+	inferred, err := findStructDeps(zero)
+	if err != nil {
+		return nil, err
+	}
+	if !placeholders {
+		if len(inferred) == 0 {
+			return nil, nil
+		}
+		return inferred, nil
+	}
+	t := reflect.TypeOf(zero).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || strings.TrimSpace(sf.Tag.Get("core")) == "" {
+			continue
+		}
+		if _, ok := inferred[sf.Name]; !ok {
+			inferred[sf.Name] = DepRef{}
+		}
+	}
+	if len(inferred) == 0 {
+		return nil, nil
+	}
+	return inferred, nil
+}
+
+// Scaffold writes a config file for adapterID to the default registry's search path.
+func Scaffold(adapterID, name string) (string, error) {
+	return defaultRegistry.Scaffold(adapterID, name)
+}
@@ -0,0 +1,101 @@
+package core_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// ScaffoldAdp has adapter and item configs and struct dependencies.
+type ScaffoldAdp struct {
+	Spec struct {
+		Region string `json:"region" default:"eu-west-1"`
+	}
+	Items map[string]*ScaffoldItem
+
+	Lister  core.Lister `core:"lister-adp"`
+	Backend core.Lister `core:"required"`
+}
+
+type ScaffoldItem struct {
+	Replicas int         `json:"replicas" default:"2"`
+	Token    core.Secret `json:"token"`
+}
+
+func (a *ScaffoldAdp) ConfigPtr() any { return &a.Spec }
+
+func (a *ScaffoldAdp) ItemConfigPtr(name string) any {
+	if a.Items == nil {
+		a.Items = make(map[string]*ScaffoldItem)
+	}
+	if a.Items[name] == nil {
+		a.Items[name] = &ScaffoldItem{}
+	}
+	return a.Items[name]
+}
+
+func TestRegistry_Scaffold(t *testing.T) {
+	core.Register("scaffold-adp", func() core.Adapter { return &ScaffoldAdp{} })
+
+	dir := t.TempDir()
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	p, err := core.Scaffold("scaffold-adp", "")
+	if err != nil {
+		t.Fatalf("Scaffold: %v", err)
+	}
+	if p != filepath.Join(dir, "scaffold-adp.json") {
+		t.Fatalf("path = %s", p)
+	}
+	data, _ := os.ReadFile(p)
+	want := `{
+  "dependencies": {
+    "Backend": {
+      "adapter": ""
+    },
+    "Lister": {
+      "adapter": "lister-adp"
+    }
+  },
+  "spec": {
+    "region": "eu-west-1"
+  }
+}
+`
+	if string(data) != want {
+		t.Fatalf("adapter config =\n%s\nwant\n%s", data, want)
+	}
+
+	p, err = core.Scaffold("scaffold-adp", "env/prod")
+	if err != nil {
+		t.Fatalf("Scaffold(item): %v", err)
+	}
+	data, _ = os.ReadFile(p)
+	want = `{
+  "adapter": "scaffold-adp",
+  "dependencies": {
+    "Lister": {
+      "adapter": "lister-adp"
+    }
+  },
+  "spec": {
+    "replicas": 2,
+    "token": {
+      "$secret": "provider:ref"
+    }
+  }
+}
+`
+	if string(data) != want {
+		t.Fatalf("item config =\n%s\nwant\n%s", data, want)
+	}
+
+	if _, err := core.Scaffold("scaffold-adp", "env/prod"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Scaffold over existing file: err = %v, want ErrExist", err)
+	}
+}
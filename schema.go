@@ -0,0 +1,297 @@
+package core
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDialect is the JSON Schema draft emitted by Schema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema needed to describe config files.
+type Schema struct {
+	Dialect              string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Const                any                `json:"const,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"` // bool or *Schema
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// AdapterIDs returns the registered adapter IDs in sorted order.
+func (r *Registry) AdapterIDs() []string {
+	r.mu.RLock()
+	ids := make([]string, 0, len(r.factories))
+	for id := range r.factories {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// schemaKey is the config key that points editors at a schema file.
+const schemaKey = "$schema"
+
+// Schema returns the JSON Schema for a config file of the given adapter: the
+// MetaHeader envelope with a typed spec and the injectable dependency fields,
+// defaulting to the adapter IDs from `core` struct tags.
+func (r *Registry) Schema(adapterID string) (*Schema, error) {
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		return nil, err
+	}
+	zero := zeroFac()
+	id := strings.ToLower(adapterID)
+
+	s := schemaForType(reflect.TypeOf(MetaHeader{}), map[reflect.Type]bool{})
+	s.Dialect = JSONSchemaDialect
+	s.Title = id + " config"
+	s.Properties["adapter"] = &Schema{Type: "string", Const: id}
+	// Editors find the schema through a "$schema" key in the config.
+	s.Properties[schemaKey] = &Schema{Type: "string"}
+
+	// Spec: adapter-level and item-level configs may use different structs.
+	var specs []*Schema
+	seen := map[reflect.Type]bool{}
+	addSpec := func(ptr any) {
+		t := reflect.TypeOf(ptr)
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+		specs = append(specs, schemaForType(t, map[reflect.Type]bool{}))
+	}
+	if c, ok := zero.(Configurable); ok {
+		addSpec(c.ConfigPtr())
+	}
+	if ic, ok := zero.(ItemConfigurable); ok {
+		addSpec(ic.ItemConfigPtr(""))
+	}
+	for _, spec := range specs {
+		allowReferences(spec)
+	}
+	switch len(specs) {
+	case 0:
+		delete(s.Properties, "spec")
+	case 1:
+		s.Properties["spec"] = specs[0]
+	default:
+		s.Properties["spec"] = &Schema{AnyOf: specs}
+	}
+
+	// Dependencies: injectable fields (with `core` tag defaults), free-form
+	// names for Dependers.
+	inferred, err := findStructDeps(zero)
+	if err != nil {
+		return nil, err
+	}
+	deps := s.Properties["dependencies"]
+	depRef := deps.AdditionalProperties.(*Schema)
+	deps.Properties = make(map[string]*Schema)
+	for _, name := range injectableFields(zero) {
+		ds := schemaForType(reflect.TypeOf(DepRef{}), map[reflect.Type]bool{})
+		if ref, ok := inferred[name]; ok {
+			ds.Properties["adapter"].Default = ref.Adapter
+		}
+		deps.Properties[name] = ds
+	}
+	if _, ok := zero.(Depender); !ok {
+		deps.AdditionalProperties = false
+		if len(deps.Properties) == 0 {
+			delete(s.Properties, "dependencies")
+		}
+	} else {
+		deps.AdditionalProperties = depRef
+	}
+
+	return s, nil
+}
+
+// Schemas returns the config file schema of every registered adapter, keyed by adapter ID.
+func (r *Registry) Schemas() (map[string]*Schema, error) {
+	out := make(map[string]*Schema)
+	for _, id := range r.AdapterIDs() {
+		s, err := r.Schema(id)
+		if err != nil {
+			return nil, fmt.Errorf("schema for %s: %w", id, err)
+		}
+		out[id] = s
+	}
+	return out, nil
+}
+
+// injectableFields lists the exported pointer and interface fields of an
+// adapter struct, which are the targets of struct dependency injection.
+func injectableFields(adapter Adapter) []string {
+	t := reflect.TypeOf(adapter)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	t = t.Elem()
+
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if k := sf.Type.Kind(); k == reflect.Interface || k == reflect.Ptr {
+			out = append(out, sf.Name)
+		}
+	}
+	return out
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// schemaForType describes how encoding/json decodes into t.
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case reflect.PointerTo(t).Implements(jsonUnmarshalerType):
+		return &Schema{}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{
+			Type:                 "object",
+			Properties:           make(map[string]*Schema),
+			AdditionalProperties: false,
+		}
+		for _, f := range jsonFields(t) {
+			fs := schemaForType(f.typ, visiting)
+			applyTagSchema(fs, f.tag)
+			s.Properties[f.name] = fs
+		}
+		return s
+	}
+	return &Schema{}
+}
+
+// allowReferences lets the leaf values of a spec schema be given as the
+// reference objects that are resolved at load time, such as
+// {"$secret": "..."}, instead of literals.
+func allowReferences(s *Schema) {
+	if s == nil {
+		return
+	}
+	switch s.Type {
+	case "object", "array", "":
+		for _, p := range s.Properties {
+			allowReferences(p)
+		}
+		if ap, ok := s.AdditionalProperties.(*Schema); ok {
+			allowReferences(ap)
+		}
+		allowReferences(s.Items)
+		for _, alt := range s.AnyOf {
+			allowReferences(alt)
+		}
+		return
+	}
+	alts := referenceAlternatives(s)
+	if len(alts) == 0 {
+		return
+	}
+	leaf := *s
+	*s = Schema{Default: leaf.Default, AnyOf: append([]*Schema{&leaf}, alts...)}
+}
+
+// referenceAlternatives returns the reference objects that can stand in for
+// a leaf value. Config references and outputs can hold any type; secrets
+// resolve to strings.
+func referenceAlternatives(leaf *Schema) []*Schema {
+	out := []*Schema{referenceObject(refKey), referenceObject(outputKey)}
+	if leaf.Type == "string" {
+		out = append(out, referenceObject(secretKey))
+	}
+	return out
+}
+
+// referenceObject describes {"<key>": "<reference>"}.
+func referenceObject(key string) *Schema {
+	return &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{key: {Type: "string"}},
+		Required:             []string{key},
+		AdditionalProperties: false,
+	}
+}
+
+// applyTagSchema adds `default` and `validate:"oneof=..."` tag values to s.
+func applyTagSchema(s *Schema, tag reflect.StructTag) {
+	if def, ok := tag.Lookup("default"); ok {
+		var v any
+		if s.Type == "string" || json.Unmarshal([]byte(def), &v) != nil {
+			v = def
+		}
+		s.Default = v
+	}
+	for _, rule := range strings.Split(tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "oneof" {
+			continue
+		}
+		for _, a := range strings.Fields(arg) {
+			var v any
+			if s.Type == "string" || json.Unmarshal([]byte(a), &v) != nil {
+				v = a
+			}
+			s.Enum = append(s.Enum, v)
+		}
+	}
+}
+
+// Schemas returns the config file schemas of the default registry.
+func Schemas() (map[string]*Schema, error) {
+	return defaultRegistry.Schemas()
+}
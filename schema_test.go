@@ -0,0 +1,61 @@
+package core_test
+
+import (
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// TaggedAdp declares its dependency adapter in a `core` tag.
+type TaggedAdp struct {
+	Spec struct {
+		Replicas int      `json:"replicas"`
+		Tags     []string `json:"tags"`
+	}
+	Lister core.Lister `core:"lister-adp,required"`
+}
+
+func (a *TaggedAdp) ConfigPtr() any { return &a.Spec }
+
+func TestRegistry_Schema(t *testing.T) {
+	core.Register("tagged-adp", func() core.Adapter { return &TaggedAdp{} })
+
+	s, err := core.DefaultRegistry().Schema("tagged-adp")
+	if err != nil {
+		t.Fatalf("Schema: %v", err)
+	}
+	if got := s.Properties["adapter"].Const; got != "tagged-adp" {
+		t.Fatalf("adapter const = %v, want tagged-adp", got)
+	}
+	if p, ok := s.Properties["$schema"]; !ok || p.Type != "string" {
+		t.Fatalf("$schema property = %+v, want a string", p)
+	}
+
+	spec := s.Properties["spec"]
+	// Leaves may also be given as references, resolved at load time.
+	replicas := spec.Properties["replicas"]
+	if len(replicas.AnyOf) != 3 || replicas.AnyOf[0].Type != "integer" {
+		t.Fatalf("spec.replicas = %+v, want an integer or a reference", replicas)
+	}
+	for i, key := range []string{"$ref", "$output"} {
+		if _, ok := replicas.AnyOf[i+1].Properties[key]; !ok {
+			t.Fatalf("spec.replicas alternative %d = %+v, want a %s object", i+1, replicas.AnyOf[i+1], key)
+		}
+	}
+	// String leaves can be secrets too.
+	items := spec.Properties["tags"].Items
+	if len(items.AnyOf) != 4 || items.AnyOf[0].Type != "string" {
+		t.Fatalf("spec.tags items = %+v, want a string or a reference", items)
+	}
+	if _, ok := items.AnyOf[3].Properties["$secret"]; !ok {
+		t.Fatalf("spec.tags items alternative = %+v, want a $secret object", items.AnyOf[3])
+	}
+
+	dep, ok := s.Properties["dependencies"].Properties["Lister"]
+	if !ok {
+		t.Fatalf("dependencies.Lister missing from schema")
+	}
+	if got := dep.Properties["adapter"].Default; got != "lister-adp" {
+		t.Fatalf("dependencies.Lister.adapter default = %v, want lister-adp", got)
+	}
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretKey is the spec key that marks a secret reference:
+//
+//	"password": {"$secret": "vault-local:db/password"}
+const secretKey = "$secret"
+
+const redacted = "[REDACTED]"
+
+// Secret is a string that redacts itself when printed or marshalled.
+// Use Reveal to get the plain value.
+type Secret string
+
+// Reveal returns the plain secret value.
+func (s Secret) Reveal() string { return string(s) }
+
+func (s Secret) String() string   { return redacted }
+func (s Secret) GoString() string { return redacted }
+
+// Format redacts the secret for every verb, including %v, %s, %q and %#v.
+func (s Secret) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(redacted))
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Secret(v)
+	return nil
+}
+
+// EnvSecretProvider resolves "env:NAME" to the value of environment variable NAME.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Secret(ctx context.Context, ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return v, nil
+}
+
+// FileSecretProvider resolves "file:path" to the contents of the file at path,
+// without a trailing newline.
+type FileSecretProvider struct{}
+
+func (FileSecretProvider) Secret(ctx context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// StaticSecretProvider serves secrets from a map. It is meant as a stand-in
+// for real providers in tests; the map can also be set from its spec:
+//
+//	core.Register("vault-local", func() core.Adapter {
+//		return &core.StaticSecretProvider{Secrets: map[string]string{"db/password": "test"}}
+//	})
+type StaticSecretProvider struct {
+	Secrets map[string]string
+}
+
+func (p *StaticSecretProvider) ConfigPtr() any {
+	return &p.Secrets
+}
+
+func (p *StaticSecretProvider) Secret(ctx context.Context, ref string) (string, error) {
+	v, ok := p.Secrets[ref]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", ref)
+	}
+	return v, nil
+}
+
+// builtinSecretProviders are used when no adapter is registered under the
+// provider name.
+var builtinSecretProviders = map[string]SecretProvider{
+	"env":  EnvSecretProvider{},
+	"file": FileSecretProvider{},
+}
+
+// secretProvider finds the provider for name: a registered adapter ID, an
+// item config whose adapter is a SecretProvider, or a builtin provider.
+func (r *Registry) secretProvider(name string) (SecretProvider, error) {
+	if r.IsRegistered(name) {
+		return NewAdapterAsFrom[SecretProvider](r, name)
+	}
+	if r.searchMap != nil {
+		meta, err := r.searchMap.Load(name, false)
+		if err == nil && meta.Adapter != "" {
+			return NewAdapterAsFrom[SecretProvider](r, meta.Adapter, name)
+		}
+	}
+	if p, ok := builtinSecretProviders[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown secret provider %q", name)
+}
+
+// resolveSecret resolves a "<provider>:<ref>" secret reference.
+func (r *Registry) resolveSecret(ctx context.Context, ref string) (string, error) {
+	name, path, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q: want <provider>:<ref>", ref)
+	}
+	p, err := r.secretProvider(name)
+	if err != nil {
+		return "", err
+	}
+	v, err := p.Secret(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", ref, err)
+	}
+	return v, nil
+}
+
+// resolveSecretRefs returns copies of the metas with every secret reference in
+// their spec replaced by fn(ref).
+func resolveSecretRefs(fn func(ref string) (string, error), metas ...*MetaHeader) ([]*MetaHeader, error) {
+	return resolveRefs(secretKey, func(ref string) (any, error) { return fn(ref) }, metas...)
+}
+
+// checkSecretRef verifies a secret reference names a known provider without
+// resolving it.
+func (r *Registry) checkSecretRef(ref string) (string, error) {
+	name, _, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q: want <provider>:<ref>", ref)
+	}
+	if r.IsRegistered(name) {
+		return redacted, nil
+	}
+	if r.searchMap != nil {
+		if meta, err := r.searchMap.Load(name, false); err == nil && meta.Adapter != "" {
+			return redacted, nil
+		}
+	}
+	if _, ok := builtinSecretProviders[strings.ToLower(name)]; ok {
+		return redacted, nil
+	}
+	return "", fmt.Errorf("unknown secret provider %q", name)
+}
+
+// redactSecretRef stands in for a secret value where it must not be revealed.
+func redactSecretRef(ref string) (string, error) {
+	return redacted, nil
+}
@@ -0,0 +1,85 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// SecretAdp receives secrets through its spec.
+type SecretAdp struct {
+	Spec struct {
+		Password core.Secret `json:"password"`
+		Token    string      `json:"token"`
+	}
+}
+
+func (a *SecretAdp) ConfigPtr() any { return &a.Spec }
+
+func TestSecret_ResolvedFromProviders(t *testing.T) {
+	t.Setenv("CORE_TEST_TOKEN", "tok-123")
+
+	core.Register("vault-local", func() core.Adapter {
+		return &core.StaticSecretProvider{Secrets: map[string]string{"db/password": "hunter2"}}
+	})
+	core.Register("secret-adp", func() core.Adapter { return &SecretAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "secret-adp.json"), `{"spec":{
+		"password": {"$secret": "vault-local:db/password"},
+		"token": {"$secret": "env:CORE_TEST_TOKEN"}
+	}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a, err := core.NewAdapterAs[*SecretAdp]("secret-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if got := a.Spec.Password.Reveal(); got != "hunter2" {
+		t.Fatalf("Password = %q, want hunter2", got)
+	}
+	if got := a.Spec.Token; got != "tok-123" {
+		t.Fatalf("Token = %q, want tok-123", got)
+	}
+	if got := fmt.Sprintf("%v %s %+v", a.Spec.Password, a.Spec.Password, a.Spec); got != "[REDACTED] [REDACTED] {Password:[REDACTED] Token:tok-123}" {
+		t.Fatalf("formatted secret = %q", got)
+	}
+}
+
+// ctxVault fails with the context's error, like a remote provider would.
+type ctxVault struct{}
+
+func (*ctxVault) Secret(ctx context.Context, ref string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "s3cret", nil
+}
+
+// CtxSecretAdp is created with a secret from ctxVault.
+type CtxSecretAdp struct{ SecretAdp }
+
+func (a *CtxSecretAdp) Create(ctx context.Context, in ...string) error { return nil }
+
+func TestSecret_ProviderGetsCallerContext(t *testing.T) {
+	core.Register("ctx-vault", func() core.Adapter { return &ctxVault{} })
+	core.Register("ctx-secret-adp", func() core.Adapter { return &CtxSecretAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ctx-secret-adp.json"), `{"spec":{"password": {"$secret": "ctx-vault:pw"}}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := core.Create(ctx, "ctx-secret-adp"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Create with a cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -1,24 +1,26 @@
 package core
 
 var (
-	NewExecutorAdapter      = NewAdapterAs[Executor]
-	NewBuilderAdapter       = NewAdapterAs[Builder]
-	NewCreaterAdapter       = NewAdapterAs[Creater]
-	NewUpdaterAdapter       = NewAdapterAs[Updater]
-	NewDeleterAdapter       = NewAdapterAs[Deleter]
-	NewReloaderAdapter      = NewAdapterAs[Reloader]
-	NewLifecycleAdapter     = NewAdapterAs[Lifecycle]
-	NewStarterAdapter       = NewAdapterAs[Starter]
-	NewStopperAdapter       = NewAdapterAs[Stopper]
-	NewRunnerAdapter        = NewAdapterAs[Runner]
-	NewListerAdapter        = NewAdapterAs[Lister]
-	NewDescriberAdapter     = NewAdapterAs[Describer]
-	NewBrowserAdapter       = NewAdapterAs[Browser]
-	NewAuthenticatorAdapter = NewAdapterAs[Authenticator]
-	NewConfigurerAdapter    = NewAdapterAs[Configurer]
-	NewUploaderAdapter      = NewAdapterAs[Uploader]
-	NewDownloaderAdapter    = NewAdapterAs[Downloader]
-	NewTransfererAdapter    = NewAdapterAs[Transferer]
-	NewFilterAdapter        = NewAdapterAs[Filter]
-	NewPrunerAdapter        = NewAdapterAs[Pruner]
+	NewExecutorAdapter       = NewAdapterAs[Executor]
+	NewBuilderAdapter        = NewAdapterAs[Builder]
+	NewCreaterAdapter        = NewAdapterAs[Creater]
+	NewUpdaterAdapter        = NewAdapterAs[Updater]
+	NewDeleterAdapter        = NewAdapterAs[Deleter]
+	NewReloaderAdapter       = NewAdapterAs[Reloader]
+	NewLifecycleAdapter      = NewAdapterAs[Lifecycle]
+	NewStarterAdapter        = NewAdapterAs[Starter]
+	NewStopperAdapter        = NewAdapterAs[Stopper]
+	NewRunnerAdapter         = NewAdapterAs[Runner]
+	NewListerAdapter         = NewAdapterAs[Lister]
+	NewDescriberAdapter      = NewAdapterAs[Describer]
+	NewDescriberOfAdapter    = NewAdapterAs[DescriberOf[Description]]
+	NewBrowserAdapter        = NewAdapterAs[Browser]
+	NewAuthenticatorAdapter  = NewAdapterAs[Authenticator]
+	NewConfigurerAdapter     = NewAdapterAs[Configurer]
+	NewUploaderAdapter       = NewAdapterAs[Uploader]
+	NewDownloaderAdapter     = NewAdapterAs[Downloader]
+	NewTransfererAdapter     = NewAdapterAs[Transferer]
+	NewFilterAdapter         = NewAdapterAs[Filter]
+	NewPrunerAdapter         = NewAdapterAs[Pruner]
+	NewSecretProviderAdapter = NewAdapterAs[SecretProvider]
 )
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Violation is a single failed `validate` rule on a spec field.
+type Violation struct {
+	Pointer string // JSON pointer of the field, e.g. /spec/replicas
+	Rule    string // rule that failed, e.g. "min=1"
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Pointer, v.Message)
+}
+
+// ValidationError reports every violation found in a spec.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = v.String()
+	}
+	return fmt.Sprintf("invalid spec:\n  - %s", strings.Join(lines, "\n  - "))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyDefaults sets `default:"..."` tag values on zero fields of the struct
+// ptr points to, recursing into nested structs.
+func applyDefaults(ptr any) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return applyStructDefaults(v)
+}
+
+func applyStructDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+
+		if def, ok := sf.Tag.Lookup("default"); ok && field.IsZero() {
+			if err := setFromString(field, def); err != nil {
+				return fmt.Errorf("default for field %s: %w", sf.Name, err)
+			}
+			continue
+		}
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := applyStructDefaults(field); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Ptr && !field.IsNil() && field.Elem().Kind() == reflect.Struct:
+			if err := applyStructDefaults(field.Elem()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setFromString assigns a tag value to field. Strings are taken literally,
+// durations are parsed with time.ParseDuration and anything else is decoded
+// as JSON (e.g. `default:"[\"a\",\"b\"]"`).
+func setFromString(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if tu, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
+	}
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(s)
+		return nil
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	return json.Unmarshal([]byte(s), field.Addr().Interface())
+}
+
+// validateSpec checks the `validate:"..."` tags of the struct ptr points to and
+// returns a *ValidationError listing all violations.
+func validateSpec(ptr any, base string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var out []Violation
+	validateStruct(v, base, &out)
+	if len(out) > 0 {
+		return &ValidationError{Violations: out}
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, ptr string, out *[]Violation) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+		fieldPtr := ptr + jsonPointer(jsonName(sf))
+
+		for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			if msg := checkRule(field, rule); msg != "" {
+				*out = append(*out, Violation{Pointer: fieldPtr, Rule: rule, Message: msg})
+			}
+		}
+
+		for field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		switch field.Kind() {
+		case reflect.Struct:
+			validateStruct(field, fieldPtr, out)
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < field.Len(); j++ {
+				elem := field.Index(j)
+				for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+					elem = elem.Elem()
+				}
+				if elem.Kind() == reflect.Struct {
+					validateStruct(elem, fmt.Sprintf("%s/%d", fieldPtr, j), out)
+				}
+			}
+		}
+	}
+}
+
+// checkRule evaluates one rule against field and returns a message on failure.
+// Supported rules: required, oneof=a b c, min=N and max=N. min and max compare
+// numbers by value and strings, slices and maps by length.
+func checkRule(field reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	switch name {
+	case "required":
+		if field.IsZero() {
+			return "is required"
+		}
+	case "oneof":
+		if field.IsZero() {
+			return ""
+		}
+		allowed := strings.Fields(arg)
+		got := fmt.Sprint(reflect.Indirect(field).Interface())
+		for _, a := range allowed {
+			if got == a {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %q", strings.Join(allowed, " "), got)
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid rule %q", rule)
+		}
+		n, isLen, ok := measure(field)
+		if !ok {
+			return ""
+		}
+		what := "be"
+		if isLen {
+			what = "have length"
+		}
+		if name == "min" && n < limit {
+			return fmt.Sprintf("must %s at least %s", what, arg)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("must %s at most %s", what, arg)
+		}
+	default:
+		return fmt.Sprintf("unknown rule %q", rule)
+	}
+	return ""
+}
+
+// measure returns the numeric value or length of field.
+func measure(field reflect.Value) (n float64, isLen, ok bool) {
+	field = reflect.Indirect(field)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(field.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(field.Len()), true, true
+	}
+	return 0, false, false
+}
+
+// jsonName returns the JSON key encoding/json uses for a struct field.
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
@@ -0,0 +1,121 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// TaggedSpecAdp declares defaults and validation rules on its spec.
+type TaggedSpecAdp struct {
+	Spec struct {
+		Region   string        `json:"region" default:"eu-west1" validate:"oneof=eu-west1 us-east1"`
+		Replicas int           `json:"replicas" default:"2" validate:"min=1,max=5"`
+		Timeout  time.Duration `json:"timeout" default:"30s"`
+		Owner    string        `json:"owner" validate:"required"`
+	}
+}
+
+func (a *TaggedSpecAdp) ConfigPtr() any { return &a.Spec }
+
+func TestSpec_DefaultsAndValidation(t *testing.T) {
+	core.Register("tagged-spec-adp", func() core.Adapter { return &TaggedSpecAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tagged-spec-adp.json"), `{"spec":{"owner":"ops","replicas":3}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a, err := core.NewAdapterAs[*TaggedSpecAdp]("tagged-spec-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.Spec.Region != "eu-west1" || a.Spec.Replicas != 3 || a.Spec.Timeout != 30*time.Second {
+		t.Fatalf("Spec = %+v, want defaults applied under the config", a.Spec)
+	}
+
+	// A separate ID so the cached instance above isn't reused.
+	core.Register("tagged-spec-bad", func() core.Adapter { return &TaggedSpecAdp{} })
+	dir = t.TempDir()
+	writeFile(t, filepath.Join(dir, "tagged-spec-bad.json"), `{"spec":{"region":"mars","replicas":9}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	_, err = core.NewAdapter("tagged-spec-bad")
+	var ve *core.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("NewAdapter error = %v, want *ValidationError", err)
+	}
+	var got []string
+	for _, v := range ve.Violations {
+		got = append(got, v.Pointer)
+	}
+	want := []string{"/spec/region", "/spec/replicas", "/spec/owner"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("violations at %v, want %v (%v)", got, want, err)
+	}
+}
+
+// TaggedItemSpecAdp overlays item configs onto its adapter-level spec.
+type TaggedItemSpecAdp struct {
+	TaggedSpecAdp
+}
+
+func (a *TaggedItemSpecAdp) ItemConfigPtr(name string) any { return &a.Spec }
+
+func TestSpec_ValidateItemOverAdapterConfig(t *testing.T) {
+	core.Register("tagged-item-spec-adp", func() core.Adapter { return &TaggedItemSpecAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tagged-item-spec-adp.json"), `{"spec":{"owner":"ops"}}`)
+	writeFile(t, filepath.Join(dir, "items", "east.json"), `{"adapter":"tagged-item-spec-adp","spec":{"region":"us-east1"}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	problems, err := core.Validate()
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("problems = %v, want none: the owner comes from the adapter config", problems)
+	}
+}
+
+// SharedSpecAdp uses one struct for its adapter and item configs.
+type SharedSpecAdp struct {
+	Spec struct {
+		Enabled bool   `json:"enabled" default:"true"`
+		Region  string `json:"region"`
+	}
+}
+
+func (a *SharedSpecAdp) ConfigPtr() any                { return &a.Spec }
+func (a *SharedSpecAdp) ItemConfigPtr(name string) any { return &a.Spec }
+
+func TestSpec_SharedStructKeepsExplicitZero(t *testing.T) {
+	id := runID("shared-spec-adp")
+	core.Register(id, func() core.Adapter { return &SharedSpecAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, id+".json"), `{"spec":{"enabled":false}}`)
+	writeFile(t, filepath.Join(dir, "items", "east.json"), fmt.Sprintf(`{"adapter":%q,"spec":{"region":"us-east1"}}`, id))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a, err := core.NewAdapterAs[*SharedSpecAdp](id, "items/east")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.Spec.Enabled || a.Spec.Region != "us-east1" {
+		t.Fatalf("Spec = %+v, want enabled=false from the adapter config and the item's region", a.Spec)
+	}
+}
@@ -0,0 +1,280 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateStore keeps what adapters created between CLI invocations, keyed by
+// item. Load and Delete report a missing key with an error wrapping
+// os.ErrNotExist. Lock takes an exclusive lock on key, waiting until it is
+// free or ctx is done; concurrent writers to one item should hold it.
+type StateStore interface {
+	Load(ctx context.Context, key string, v any) error
+	Save(ctx context.Context, key string, v any) error
+	Delete(ctx context.Context, key string) error
+	Lock(ctx context.Context, key string) (unlock func() error, err error)
+}
+
+// State is a StateStore scoped to one item.
+type State struct {
+	store StateStore
+	key   string
+}
+
+// NewState scopes store to key.
+func NewState(store StateStore, key string) *State {
+	return &State{store: store, key: key}
+}
+
+// Key returns the item key the state is stored under.
+func (s *State) Key() string { return s.key }
+
+func (s *State) Load(ctx context.Context, v any) error { return s.store.Load(ctx, s.key, v) }
+func (s *State) Save(ctx context.Context, v any) error { return s.store.Save(ctx, s.key, v) }
+func (s *State) Delete(ctx context.Context) error      { return s.store.Delete(ctx, s.key) }
+
+func (s *State) Lock(ctx context.Context) (unlock func() error, err error) {
+	return s.store.Lock(ctx, s.key)
+}
+
+// lockPollInterval is how often a contended lock is retried.
+const lockPollInterval = 50 * time.Millisecond
+
+// FileStateStore stores each item as a JSON file under a directory, with an
+// advisory lock file per item that also excludes other processes.
+type FileStateStore struct {
+	dir string
+}
+
+// NewFileStateStore returns a store keeping its files under dir.
+func NewFileStateStore(dir string) *FileStateStore {
+	return &FileStateStore{dir: dir}
+}
+
+// Dir returns the directory the store keeps its files in.
+func (s *FileStateStore) Dir() string { return s.dir }
+
+// path returns the file of key under sub ("items" or "locks").
+func (s *FileStateStore) path(sub, key, ext string) (string, error) {
+	rel := filepath.FromSlash(cleanKey(key))
+	if key == "" || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid state key %q", key)
+	}
+	return filepath.Join(s.dir, sub, rel+ext), nil
+}
+
+func (s *FileStateStore) Load(ctx context.Context, key string, v any) error {
+	file, err := s.path("items", key, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no state for %s: %w", key, os.ErrNotExist)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", file, err)
+	}
+	return nil
+}
+
+func (s *FileStateStore) Save(ctx context.Context, key string, v any) error {
+	file, err := s.path("items", key, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state for %s: %w", key, err)
+	}
+	if err := (osFS{}).WriteFile(file, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
+
+func (s *FileStateStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path("items", key, ".json")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no state for %s: %w", key, os.ErrNotExist)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStateStore) Lock(ctx context.Context, key string) (func() error, error) {
+	file, err := s.path("locks", key, ".lock")
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", key, err)
+	}
+	return unlock, nil
+}
+
+// lockFile takes the lock file, creating its directory, and retries while
+// another holder has it until ctx is done.
+func lockFile(ctx context.Context, file string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	for {
+		unlock, err := tryLockFile(file)
+		if !errors.Is(err, errLocked) {
+			return unlock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// errLocked is returned by tryLockFile when another holder has the lock.
+var errLocked = errors.New("locked")
+
+// MemoryStateStore keeps state in memory, for tests and one-shot runs.
+type MemoryStateStore struct {
+	mu    sync.Mutex
+	items map[string]json.RawMessage
+	locks map[string]chan struct{}
+}
+
+// NewMemoryStateStore returns an empty in-memory store.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		items: make(map[string]json.RawMessage),
+		locks: make(map[string]chan struct{}),
+	}
+}
+
+func (s *MemoryStateStore) Load(ctx context.Context, key string, v any) error {
+	s.mu.Lock()
+	data, ok := s.items[key]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no state for %s: %w", key, os.ErrNotExist)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *MemoryStateStore) Save(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode state for %s: %w", key, err)
+	}
+	s.mu.Lock()
+	s.items[key] = data
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStateStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[key]; !ok {
+		return fmt.Errorf("no state for %s: %w", key, os.ErrNotExist)
+	}
+	delete(s.items, key)
+	return nil
+}
+
+func (s *MemoryStateStore) Lock(ctx context.Context, key string) (func() error, error) {
+	for {
+		s.mu.Lock()
+		held, ok := s.locks[key]
+		if !ok {
+			release := make(chan struct{})
+			s.locks[key] = release
+			s.mu.Unlock()
+			var once sync.Once
+			return func() error {
+				once.Do(func() {
+					s.mu.Lock()
+					delete(s.locks, key)
+					s.mu.Unlock()
+					close(release)
+				})
+				return nil
+			}, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("lock %s: %w", key, ctx.Err())
+		case <-held:
+		}
+	}
+}
+
+// SetStateStore sets the store handed to StateAware adapters. By default
+// items are kept in files under the SearchMap's state directory, or in memory
+// when the SearchMap has none.
+func (r *Registry) SetStateStore(s StateStore) {
+	r.mu.Lock()
+	r.stateStore = s
+	r.mu.Unlock()
+}
+
+// StateStore returns the registry's state store.
+func (r *Registry) StateStore() (StateStore, error) {
+	r.mu.RLock()
+	s := r.stateStore
+	r.mu.RUnlock()
+	if s != nil {
+		return s, nil
+	}
+	if r.searchMap == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	dir, err := r.searchMap.stateDir()
+	if errors.Is(err, errNoStateDir) {
+		// Nothing to write to: keep state for this process only.
+		Log().Warnf("%v; keeping adapter state in memory\n", err)
+		r.mu.Lock()
+		if r.stateStore == nil {
+			r.stateStore = NewMemoryStateStore()
+		}
+		s = r.stateStore
+		r.mu.Unlock()
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewFileStateStore(dir), nil
+}
+
+// stateKeyFor returns the key an adapter's state is stored under: the full
+// key of its item config, or the adapter ID.
+func stateKeyFor(adapterID string, itemMeta *MetaHeader) string {
+	if itemMeta != nil && len(itemMeta.Sources) > 0 {
+		return filepath.ToSlash(itemMeta.Sources[0].Key)
+	}
+	return strings.ToLower(adapterID)
+}
+
+// SetStateStore sets the default registry's state store.
+func SetStateStore(s StateStore) {
+	defaultRegistry.SetStateStore(s)
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// tryLockFile creates file exclusively and removes it on unlock. A run that
+// crashes while holding the lock leaves the file behind; remove it by hand.
+func tryLockFile(file string) (func() error, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return func() error { return os.Remove(file) }, nil
+}
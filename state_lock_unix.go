@@ -0,0 +1,29 @@
+//go:build unix
+
+package core
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes a non-blocking flock on file. The lock is released when
+// the process exits, so crashed runs never leave stale locks.
+func tryLockFile(file string) (func() error, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return func() error {
+		// Closing the descriptor releases the lock.
+		return f.Close()
+	}, nil
+}
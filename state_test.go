@@ -0,0 +1,109 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+type bucketState struct {
+	ID string `json:"id"`
+}
+
+func testStateStore(t *testing.T, store core.StateStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	var got bucketState
+	if err := store.Load(ctx, "storage/main", &got); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load before Save err = %v, want ErrNotExist", err)
+	}
+	if err := store.Save(ctx, "storage/main", bucketState{ID: "b-1"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Load(ctx, "storage/main", &got); err != nil || got.ID != "b-1" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+
+	unlock, err := store.Lock(ctx, "storage/main")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := store.Lock(short, "storage/main"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Lock err = %v, want deadline exceeded", err)
+	}
+	other, err := store.Lock(ctx, "storage/other")
+	if err != nil {
+		t.Fatalf("Lock other item: %v", err)
+	}
+	_ = other()
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	again, err := store.Lock(ctx, "storage/main")
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+	_ = again()
+
+	if err := store.Delete(ctx, "storage/main"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, "storage/main"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("second Delete err = %v, want ErrNotExist", err)
+	}
+}
+
+func TestFileStateStore(t *testing.T) {
+	store := core.NewFileStateStore(t.TempDir())
+	testStateStore(t, store)
+
+	if err := store.Save(context.Background(), "../escape", bucketState{}); err == nil {
+		t.Fatalf("Save outside the store succeeded")
+	}
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	testStateStore(t, core.NewMemoryStateStore())
+}
+
+// StatefulAdp records the state it is handed.
+type StatefulAdp struct {
+	state *core.State
+}
+
+func (a *StatefulAdp) ItemConfigPtr(name string) any { return nil }
+func (a *StatefulAdp) SetState(state *core.State)    { a.state = state }
+
+func TestRegistry_StateAware(t *testing.T) {
+	id := runID("stateful")
+	core.Register(id, func() core.Adapter { return &StatefulAdp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "storage", "main.json"), fmt.Sprintf(`{"adapter":%q}`, id))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a, err := core.NewAdapterAs[*StatefulAdp](id, "storage/main")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a.state == nil || a.state.Key() != "storage/main" {
+		t.Fatalf("state = %+v, want key storage/main", a.state)
+	}
+	if err := a.state.Save(context.Background(), bucketState{ID: "b-2"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, core.StateDir, "items", "storage", "main.json")); err != nil {
+		t.Fatalf("state file: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status states, from healthy to failing.
+const (
+	StatusReady    = "ready"
+	StatusUnknown  = "unknown"   // the adapter reports no status
+	StatusDegraded = "degraded"  // working with reduced capacity
+	StatusNotReady = "not_ready" // failing, erroring or timed out
+)
+
+// Status is what a StatusReporter says about itself.
+type Status struct {
+	State   string         `json:"state"` // one of the Status* states
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// statusSeverity orders states for roll-ups. Unrecognized states count as not
+// ready.
+func statusSeverity(state string) int {
+	switch state {
+	case StatusReady:
+		return 0
+	case StatusUnknown:
+		return 1
+	case StatusDegraded:
+		return 2
+	}
+	return 3
+}
+
+// worseStatus returns the less healthy of two states.
+func worseStatus(a, b string) string {
+	if statusSeverity(b) > statusSeverity(a) {
+		return b
+	}
+	return a
+}
+
+// AdapterStatus is the status of one cached adapter.
+type AdapterStatus struct {
+	Key          string        `json:"key"` // registry cache key
+	Adapter      string        `json:"adapter"`
+	Args         []string      `json:"args,omitempty"`
+	Status       Status        `json:"status"`
+	Rollup       string        `json:"rollup"` // worst state of the adapter and its dependencies
+	Dependencies []string      `json:"dependencies,omitempty"`
+	Duration     time.Duration `json:"duration"`
+}
+
+// StatusReport is the status of every cached adapter.
+type StatusReport struct {
+	State     string          `json:"state"` // worst state overall
+	CheckedAt time.Time       `json:"checked_at"`
+	Adapters  []AdapterStatus `json:"adapters"`
+}
+
+// Ready reports whether every adapter is ready or reports no status. Degraded
+// adapters still count as ready.
+func (r *StatusReport) Ready() bool {
+	return statusSeverity(r.State) <= statusSeverity(StatusDegraded)
+}
+
+// WriteText renders the report as aligned text, one adapter per line.
+func (r *StatusReport) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "status: %s\n", r.State); err != nil {
+		return err
+	}
+	width := 0
+	for _, a := range r.Adapters {
+		width = max(width, len(a.Key))
+	}
+	for _, a := range r.Adapters {
+		line := fmt.Sprintf("%-*s  %-9s", width, a.Key, a.Status.State)
+		if a.Rollup != a.Status.State {
+			line += " (" + a.Rollup + " via dependencies)"
+		}
+		if a.Status.Message != "" {
+			line += "  " + a.Status.Message
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckStatus queries every cached adapter implementing StatusReporter
+// concurrently, each under its own timeout, and rolls the states up through
+// the dependency graph: an adapter is no healthier than what it depends on.
+// Errors and timeouts count as not ready; adapters without a StatusReporter
+// are unknown. A timeout <= 0 means no timeout beyond ctx.
+func (r *Registry) CheckStatus(ctx context.Context, timeout time.Duration) *StatusReport {
+	type node struct {
+		key     string
+		adapter Adapter
+		entry   adapterEntry
+	}
+	r.mu.RLock()
+	nodes := make([]node, 0, len(r.adapters))
+	for _, key := range sortedKeys(r.adapters) {
+		var e adapterEntry
+		if ep, ok := r.entries[key]; ok {
+			e = *ep
+			e.dependents = maps.Clone(ep.dependents)
+		}
+		nodes = append(nodes, node{key: key, adapter: r.adapters[key], entry: e})
+	}
+	r.mu.RUnlock()
+
+	report := &StatusReport{State: StatusReady, CheckedAt: time.Now().UTC()}
+	report.Adapters = make([]AdapterStatus, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		report.Adapters[i] = AdapterStatus{Key: n.key, Adapter: n.entry.adapterID, Args: n.entry.args}
+		sr, ok := n.adapter.(StatusReporter)
+		if !ok {
+			report.Adapters[i].Status = Status{State: StatusUnknown}
+			continue
+		}
+		wg.Add(1)
+		go func(as *AdapterStatus) {
+			defer wg.Done()
+			as.Status, as.Duration = queryStatus(ctx, sr, timeout)
+		}(&report.Adapters[i])
+	}
+	wg.Wait()
+
+	// Dependencies are the entries this adapter was injected with.
+	index := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		index[n.key] = i
+	}
+	for _, n := range nodes {
+		for parent := range n.entry.dependents {
+			if i, ok := index[parent]; ok {
+				report.Adapters[i].Dependencies = append(report.Adapters[i].Dependencies, n.key)
+			}
+		}
+	}
+	for i := range report.Adapters {
+		sort.Strings(report.Adapters[i].Dependencies)
+	}
+
+	// An adapter rolls up everything it reaches, so members of a dependency
+	// cycle see each other's states.
+	for i := range report.Adapters {
+		state := report.Adapters[i].Status.State
+		seen := map[int]bool{i: true}
+		stack := []int{i}
+		for len(stack) > 0 {
+			as := &report.Adapters[stack[len(stack)-1]]
+			stack = stack[:len(stack)-1]
+			state = worseStatus(state, as.Status.State)
+			for _, dep := range as.Dependencies {
+				if j := index[dep]; !seen[j] {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		report.Adapters[i].Rollup = state
+		report.State = worseStatus(report.State, state)
+	}
+	return report
+}
+
+// queryStatus asks sr for its status under timeout.
+func queryStatus(ctx context.Context, sr StatusReporter, timeout time.Duration) (Status, time.Duration) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		status Status
+		err    error
+	}
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		s, err := sr.Status(ctx)
+		done <- result{s, err}
+	}()
+
+	// Don't wait for reporters that ignore ctx.
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return Status{State: StatusNotReady, Message: res.err.Error()}, time.Since(start)
+		}
+		if res.status.State == "" {
+			res.status.State = StatusUnknown
+		}
+		return res.status, time.Since(start)
+	case <-ctx.Done():
+		return Status{State: StatusNotReady, Message: ctx.Err().Error()}, time.Since(start)
+	}
+}
+
+// CheckStatus checks the adapters cached in the default registry.
+func CheckStatus(ctx context.Context, timeout time.Duration) *StatusReport {
+	return defaultRegistry.CheckStatus(ctx, timeout)
+}
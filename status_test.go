@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// StatusDB reports itself degraded.
+type StatusDB struct{}
+
+func (a *StatusDB) Status(ctx context.Context) (core.Status, error) {
+	return core.Status{State: core.StatusDegraded, Message: "replica lagging"}, nil
+}
+
+// StatusApp is ready itself but depends on StatusDB.
+type StatusApp struct {
+	Store *StatusDB `core:"status-db"`
+}
+
+func (a *StatusApp) Status(ctx context.Context) (core.Status, error) {
+	return core.Status{State: core.StatusReady}, nil
+}
+
+func TestRegistry_CheckStatus(t *testing.T) {
+	dbID, appID := runID("status-db"), runID("status-app")
+	core.Register(dbID, func() core.Adapter { return &StatusDB{} })
+	core.Register(appID, func() core.Adapter { return &StatusApp{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, appID+".json"), fmt.Sprintf(`{"dependencies":{"Store":{"adapter":%q}}}`, dbID))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if _, err := core.NewAdapter(appID); err != nil {
+		t.Fatalf("NewAdapter: %v", err)
+	}
+
+	report := core.CheckStatus(context.Background(), time.Second)
+	byAdapter := make(map[string]core.AdapterStatus)
+	for _, a := range report.Adapters {
+		byAdapter[a.Adapter] = a
+	}
+	app, db := byAdapter[appID], byAdapter[dbID]
+	if app.Status.State != core.StatusReady || app.Rollup != core.StatusDegraded {
+		t.Fatalf("app = %+v, want ready rolled up to degraded", app)
+	}
+	if len(app.Dependencies) != 1 || app.Dependencies[0] != db.Key {
+		t.Fatalf("app dependencies = %v, want [%s]", app.Dependencies, db.Key)
+	}
+	if !report.Ready() {
+		t.Fatalf("report state = %s, want ready", report.State)
+	}
+}
+
+// StatusPeerA and StatusPeerB depend on each other.
+type StatusPeerA struct {
+	Peer *StatusPeerB `core:"status-peer-b"`
+}
+
+func (a *StatusPeerA) Status(ctx context.Context) (core.Status, error) {
+	return core.Status{State: core.StatusDegraded}, nil
+}
+
+type StatusPeerB struct {
+	Peer *StatusPeerA `core:"status-peer-a"`
+}
+
+func (a *StatusPeerB) Status(ctx context.Context) (core.Status, error) {
+	return core.Status{State: core.StatusReady}, nil
+}
+
+func TestRegistry_CheckStatusCycle(t *testing.T) {
+	aID, bID := runID("status-peer-a"), runID("status-peer-b")
+	core.Register(aID, func() core.Adapter { return &StatusPeerA{} })
+	core.Register(bID, func() core.Adapter { return &StatusPeerB{} })
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, aID+".json"), fmt.Sprintf(`{"dependencies":{"Peer":{"adapter":%q}}}`, bID))
+	writeFile(t, filepath.Join(dir, bID+".json"), fmt.Sprintf(`{"dependencies":{"Peer":{"adapter":%q}}}`, aID))
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	if _, err := core.NewAdapter(aID); err != nil {
+		t.Fatalf("NewAdapter: %v", err)
+	}
+
+	for _, as := range core.CheckStatus(context.Background(), time.Second).Adapters {
+		if (as.Adapter == aID || as.Adapter == bID) && as.Rollup != core.StatusDegraded {
+			t.Fatalf("%s rollup = %s, want degraded from its cycle", as.Adapter, as.Rollup)
+		}
+	}
+}
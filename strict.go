@@ -0,0 +1,274 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UnknownField is a config key that doesn't map to any field of the target.
+type UnknownField struct {
+	File       string // config file path
+	Pointer    string // JSON pointer of the unknown key
+	Suggestion string // nearest known field name, if any
+}
+
+func (u UnknownField) String() string {
+	s := fmt.Sprintf("%s#%s", u.File, u.Pointer)
+	if u.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %q?)", u.Suggestion)
+	}
+	return s
+}
+
+// UnknownFieldsError reports every unknown key found in strict mode.
+type UnknownFieldsError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	lines := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		lines[i] = f.String()
+	}
+	return fmt.Sprintf("unknown config fields:\n  - %s", strings.Join(lines, "\n  - "))
+}
+
+// SetStrict enables or disables strict config decoding for this registry.
+// Adapters implementing StrictConfigurable override this setting.
+func (r *Registry) SetStrict(strict bool) {
+	r.mu.Lock()
+	r.strict = strict
+	r.mu.Unlock()
+}
+
+// isStrict reports whether configs for adapter must be decoded strictly.
+func (r *Registry) isStrict(adapter Adapter) bool {
+	if s, ok := adapter.(StrictConfigurable); ok {
+		return s.StrictConfig()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.strict
+}
+
+// checkUnknownFields reads every source file of meta and item meta and reports
+// all keys that are unknown to MetaHeader or to the adapter's config structs.
+func (r *Registry) checkUnknownFields(adapter Adapter, meta, itemMeta *MetaHeader) error {
+	var unknown []UnknownField
+
+	check := func(m *MetaHeader, specPtr any) error {
+		if m == nil {
+			return nil
+		}
+		for _, src := range m.Sources {
+			data, err := r.searchMap.ReadSource(src)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", src.Path, err)
+			}
+			v, err := decodeJSON(data)
+			if err != nil {
+				return fmt.Errorf("decode %s: %w", src.Path, err)
+			}
+			for _, u := range findUnknownFields(src.Path, v, reflect.TypeOf(MetaHeader{}), "") {
+				if u.Pointer != jsonPointer(schemaKey) {
+					unknown = append(unknown, u)
+				}
+			}
+
+			spec, ok := lookupPointer(v, "/spec")
+			if !ok || specPtr == nil {
+				continue
+			}
+			unknown = append(unknown, findUnknownFields(src.Path, spec, reflect.TypeOf(specPtr), "/spec")...)
+		}
+		return nil
+	}
+
+	var specPtr any
+	if c, ok := adapter.(Configurable); ok {
+		specPtr = c.ConfigPtr()
+	}
+	if err := check(meta, specPtr); err != nil {
+		return err
+	}
+
+	specPtr = nil
+	if ic, ok := adapter.(ItemConfigurable); ok && itemMeta != nil {
+		specPtr = ic.ItemConfigPtr(itemMeta.Name)
+	}
+	if err := check(itemMeta, specPtr); err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		return &UnknownFieldsError{Fields: unknown}
+	}
+	return nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// findUnknownFields walks a decoded JSON value against type t and returns the
+// keys that encoding/json would silently ignore.
+func findUnknownFields(file string, v any, t reflect.Type, ptr string) []UnknownField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || isReference(v) {
+		return nil
+	}
+
+	var out []UnknownField
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			f, ok := matchJSONField(fields, k)
+			if !ok {
+				out = append(out, UnknownField{
+					File:       file,
+					Pointer:    ptr + jsonPointer(k),
+					Suggestion: nearestName(k, fields),
+				})
+				continue
+			}
+			out = append(out, findUnknownFields(file, obj[k], f.typ, ptr+jsonPointer(k))...)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		for k, child := range obj {
+			out = append(out, findUnknownFields(file, child, t.Elem(), ptr+jsonPointer(k))...)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Pointer < out[j].Pointer })
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		for i, child := range arr {
+			out = append(out, findUnknownFields(file, child, t.Elem(), fmt.Sprintf("%s/%d", ptr, i))...)
+		}
+	}
+	return out
+}
+
+// jsonField is a struct field as seen by encoding/json.
+type jsonField struct {
+	name string
+	typ  reflect.Type
+	tag  reflect.StructTag
+}
+
+// jsonFields lists the JSON field names of struct type t, including fields
+// promoted from embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var out []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				out = append(out, jsonFields(ft)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		out = append(out, jsonField{name: name, typ: sf.Type, tag: sf.Tag})
+	}
+	return out
+}
+
+// matchJSONField finds a field by exact name, then case-insensitively.
+func matchJSONField(fields []jsonField, key string) (jsonField, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return jsonField{}, false
+}
+
+// nearestName returns the known field name closest to key, or "" if none is
+// reasonably close.
+func nearestName(key string, fields []jsonField) string {
+	best, bestDist := "", -1
+	for _, f := range fields {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(f.name))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = f.name, d
+		}
+	}
+	if bestDist < 0 || bestDist > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// decodeSpec unmarshals a spec payload, rejecting unknown fields when strict.
+func decodeSpec(data []byte, ptr any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(ptr)
+}
+
+// SetDefaultStrict enables or disables strict config decoding for the default registry.
+func SetDefaultStrict(strict bool) {
+	defaultRegistry.SetStrict(strict)
+}
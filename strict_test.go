@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// StrictAdp opts into strict config decoding.
+type StrictAdp struct {
+	Spec struct {
+		Bucket string `json:"bucket"`
+		Region string `json:"region"`
+	}
+}
+
+func (a *StrictAdp) ConfigPtr() any     { return &a.Spec }
+func (a *StrictAdp) StrictConfig() bool { return true }
+
+func TestStrict_ReportsAllUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "strict-adp.json")
+	writeFile(t, cfg, `{"$schema":"./strict-adp.schema.json","adapter":"strict-adp","work_dri":"x","spec":{"bucket":"b","regoin":"eu","extra":1}}`)
+
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+	core.Register("strict-adp", func() core.Adapter { return &StrictAdp{} })
+
+	_, err := core.NewAdapter("strict-adp")
+	var ufe *core.UnknownFieldsError
+	if !errors.As(err, &ufe) {
+		t.Fatalf("NewAdapter error = %v, want *UnknownFieldsError", err)
+	}
+
+	want := []core.UnknownField{
+		{File: cfg, Pointer: "/spec/extra"},
+		{File: cfg, Pointer: "/spec/regoin", Suggestion: "region"},
+		{File: cfg, Pointer: "/work_dri", Suggestion: "work_dir"},
+	}
+	if len(ufe.Fields) != len(want) {
+		t.Fatalf("Fields = %+v, want %+v", ufe.Fields, want)
+	}
+	for _, w := range want {
+		found := false
+		for _, f := range ufe.Fields {
+			if f == w {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("missing %+v in %+v", w, ufe.Fields)
+		}
+	}
+}
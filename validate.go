@@ -0,0 +1,250 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/bartdeboer/words"
+)
+
+// ConfigProblem is a single issue found while validating config files.
+type ConfigProblem struct {
+	File    string `json:"file"`              // config file path
+	Key     string `json:"key"`               // search map key
+	Adapter string `json:"adapter,omitempty"` // adapter ID, if known
+	Message string `json:"message"`
+}
+
+func (p ConfigProblem) String() string {
+	if p.Adapter != "" {
+		return fmt.Sprintf("%s (%s): %s", p.File, p.Adapter, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// Validate checks every config file in the SearchMap against the registered
+// adapters without constructing or hydrating them. It reports unknown
+// adapters, spec decode errors, `validate` tag violations, unresolvable or unassignable dependencies and
+// uncovered `core:"required"` fields. The returned error is only set when
+// validation could not run at all.
+func (r *Registry) Validate() ([]ConfigProblem, error) {
+	if r.searchMap == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+
+	var problems []ConfigProblem
+	for _, key := range r.searchMap.Keys() {
+		problems = append(problems, r.validateKey(key)...)
+	}
+	return problems, nil
+}
+
+func (r *Registry) validateKey(key string) []ConfigProblem {
+	var problems []ConfigProblem
+	file, _ := r.searchMap.Resolve(key)
+	report := func(adapterID, format string, args ...any) {
+		problems = append(problems, ConfigProblem{
+			File:    file,
+			Key:     key,
+			Adapter: adapterID,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	meta, err := r.searchMap.Load(key, false)
+	if err != nil {
+		report("", "%v", err)
+		return problems
+	}
+
+	// Adapter-level configs may omit the adapter key; they are named after it.
+	shortName := strings.TrimSuffix(filepath.Base(file), ".json")
+	adapterID := meta.Adapter
+	if adapterID == "" {
+		adapterID = shortName
+	}
+	adapterID = strings.ToLower(adapterID)
+	isItem := !strings.EqualFold(shortName, adapterID)
+
+	zeroFac, err := r.getFactory(adapterID)
+	if err != nil {
+		if meta.Adapter == "" {
+			report("", "no adapter set and %q is not a registered adapter", shortName)
+		} else {
+			report(adapterID, "adapter is not registered")
+		}
+		return problems
+	}
+	zero := zeroFac()
+
+	// Spec.
+	var cfgMeta, itemMeta, adapterMeta *MetaHeader
+	if isItem {
+		if _, ok := zero.(ItemConfigurable); !ok {
+			report(adapterID, "adapter does not accept item configs")
+			return problems
+		}
+		itemMeta = meta
+		// Required coverage for items includes the adapter-level dependencies.
+		adapterMeta, _ = r.searchMap.Load(adapterID, false)
+	} else {
+		cfgMeta = meta
+	}
+
+	if r.isStrict(zero) {
+		err := r.checkUnknownFields(zero, cfgMeta, itemMeta)
+		var ufe *UnknownFieldsError
+		switch {
+		case errors.As(err, &ufe):
+			for _, f := range ufe.Fields {
+				report(adapterID, "unknown field %s", f)
+			}
+		case err != nil:
+			report(adapterID, "%v", err)
+		}
+	}
+	// Secret references are checked but not resolved.
+	checked, err := resolveSecretRefs(r.checkSecretRef, cfgMeta, itemMeta)
+	if err != nil {
+		report(adapterID, "%v", err)
+		return problems
+	}
+	// Output references are checked without building the adapters.
+	checked, err = resolveRefs(outputKey, r.checkOutputRef, checked...)
+	if err != nil {
+		report(adapterID, "%v", err)
+		return problems
+	}
+	// Items are decoded over the adapter-level config, as NewAdapter does, so
+	// its values count towards the item's validation. Its own problems are
+	// reported for its file.
+	if isItem && adapterMeta != nil {
+		base, err := resolveSecretRefs(r.checkSecretRef, adapterMeta)
+		if err == nil {
+			base, err = resolveRefs(outputKey, r.checkOutputRef, base...)
+		}
+		if err == nil {
+			checked[0] = base[0]
+		}
+	}
+	// Unknown fields are reported above, so decode leniently here.
+	if err := applyConfig(zero, adapterID, checked[0], checked[1], false); err != nil {
+		report(adapterID, "%v", err)
+	} else {
+		err := validateConfig(zero, itemMeta)
+		var ve *ValidationError
+		switch {
+		case errors.As(err, &ve):
+			for _, v := range ve.Violations {
+				report(adapterID, "%s", v)
+			}
+		case err != nil:
+			report(adapterID, "%v", err)
+		}
+	}
+
+	// Dependencies.
+	inferred, err := findStructDeps(zero)
+	if err != nil {
+		report(adapterID, "%v", err)
+		return problems
+	}
+	fileDeps := mergeDeps(meta.Dependencies, inferred)
+	for _, name := range sortedKeys(fileDeps) {
+		if msg := r.checkDep(zero, name, fileDeps[name]); msg != "" {
+			report(adapterID, "dependency %q: %s", name, msg)
+		}
+	}
+
+	// Required coverage.
+	covered := map[string]bool{}
+	for name := range fileDeps {
+		covered[words.ToCapWords(name)] = true
+	}
+	if adapterMeta != nil {
+		for name := range adapterMeta.Dependencies {
+			covered[words.ToCapWords(name)] = true
+		}
+	}
+	for _, field := range requiredFields(zero) {
+		if !covered[field] {
+			report(adapterID, "required dependency %q is not configured", field)
+		}
+	}
+
+	return problems
+}
+
+// checkDep verifies a dependency resolves to a registered adapter that can be
+// assigned to its target. It returns a problem message or "".
+func (r *Registry) checkDep(target Adapter, name string, ref DepRef) string {
+	if ref.Adapter == "" {
+		return "no adapter set"
+	}
+	depFac, err := r.getFactory(ref.Adapter)
+	if err != nil {
+		return fmt.Sprintf("adapter %q is not registered", ref.Adapter)
+	}
+
+	v := reflect.ValueOf(target)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	var field reflect.Value
+	if v.Kind() == reflect.Struct {
+		field = v.FieldByName(words.ToCapWords(name))
+	}
+	if !field.IsValid() {
+		if _, ok := target.(Depender); ok {
+			return ""
+		}
+		return fmt.Sprintf("field %q not found in %T", words.ToCapWords(name), target)
+	}
+
+	depType := reflect.TypeOf(depFac())
+	if !depType.AssignableTo(field.Type()) {
+		return fmt.Sprintf("adapter %q (%s) not assignable to field %s (%s)",
+			ref.Adapter, depType, words.ToCapWords(name), field.Type())
+	}
+	return ""
+}
+
+// requiredFields lists the struct fields tagged `core:"required"` (in either
+// the single or the positional form).
+func requiredFields(target any) []string {
+	t := reflect.TypeOf(target)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	t = t.Elem()
+
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for _, p := range strings.Split(sf.Tag.Get("core"), ",") {
+			if strings.EqualFold(strings.TrimSpace(p), "required") {
+				out = append(out, sf.Name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate checks all config files of the default registry's SearchMap.
+func Validate() ([]ConfigProblem, error) {
+	return defaultRegistry.Validate()
+}
@@ -0,0 +1,328 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RefreshReport summarises what Refresh did with the cached adapters.
+type RefreshReport struct {
+	Changed      []string // config files that were added, changed or removed
+	Reconfigured []string // cache keys notified through Reconfigurable
+	Rebuilt      []string // cache keys evicted and rebuilt
+}
+
+// WatchOptions configures Registry.Watch.
+type WatchOptions struct {
+	// Interval between polls, and the debounce delay for native
+	// notifications. Defaults to 2s.
+	Interval time.Duration
+	// Poll forces polling even where native notifications are available.
+	Poll bool
+	// OnRefresh is called after every refresh. When nil, errors are logged.
+	OnRefresh func(*RefreshReport, error)
+}
+
+// Refresh re-indexes the SearchMap and updates the cached adapters built from
+// any of the changed config files. Adapters implementing Reconfigurable are
+// handed their new config in place; all others are evicted, together with
+// every cached adapter they were injected into, and rebuilt. Callers holding
+// references to rebuilt adapters should request them again.
+func (r *Registry) Refresh(changed ...string) (*RefreshReport, error) {
+	if r.searchMap == nil {
+		return nil, fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	if err := r.searchMap.Reindex(); err != nil {
+		return nil, err
+	}
+
+	report := &RefreshReport{Changed: changed}
+	paths, names := r.changedNames(changed)
+
+	r.mu.RLock()
+	var affected []string
+	for key, e := range r.entries {
+		if e.affectedBy(paths, names) {
+			affected = append(affected, key)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(affected)
+
+	var errs []error
+	evict := make(map[string]bool)
+	for _, key := range affected {
+		r.mu.RLock()
+		a, e := r.adapters[key], r.entries[key]
+		r.mu.RUnlock()
+
+		if rc, ok := a.(Reconfigurable); ok {
+			if err := r.reconfigure(key, a, e, rc); err != nil {
+				errs = append(errs, fmt.Errorf("reconfiguring %s: %w", key, err))
+			} else {
+				report.Reconfigured = append(report.Reconfigured, key)
+				continue
+			}
+		}
+		evict[key] = true
+	}
+
+	// Parents hold the evicted instances, so they are rebuilt too.
+	r.mu.Lock()
+	queue := make([]string, 0, len(evict))
+	for key := range evict {
+		queue = append(queue, key)
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		e, ok := r.entries[key]
+		if !ok {
+			continue
+		}
+		for parent := range e.dependents {
+			if !evict[parent] {
+				evict[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	// Top-level requests rebuild their dependencies on the way.
+	var rebuild []*adapterEntry
+	for key := range evict {
+		e, ok := r.entries[key]
+		if !ok {
+			continue
+		}
+		if len(e.dependents) == 0 {
+			rebuild = append(rebuild, e)
+		}
+		delete(r.adapters, key)
+		delete(r.entries, key)
+		report.Rebuilt = append(report.Rebuilt, key)
+	}
+	r.mu.Unlock()
+	sort.Strings(report.Rebuilt)
+
+	for _, e := range rebuild {
+		Log().Debugf("rebuilding adapter: %s %v\n", e.adapterID, e.args)
+		if _, err := r.newAdapterWithContext(context.Background(), "", e.adapterID, e.workDir, e.args...); err != nil {
+			errs = append(errs, fmt.Errorf("rebuilding %s: %w", e.adapterID, err))
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
+
+// reconfigure hands an adapter its freshly loaded config.
+func (r *Registry) reconfigure(regKey string, a Adapter, e *adapterEntry, rc Reconfigurable) error {
+	meta, itemMeta, err := r.loadMetas(a, e.adapterID, e.args...)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveSecretRefs(func(ref string) (string, error) {
+		return r.resolveSecret(context.Background(), ref)
+	}, meta, itemMeta)
+	if err != nil {
+		return err
+	}
+	resolved, err = resolveRefs(outputKey, func(ref string) (any, error) {
+		return r.resolveOutput(context.Background(), regKey, ref)
+	}, resolved...)
+	if err != nil {
+		return err
+	}
+
+	Log().Debugf("reconfiguring adapter: %s %v\n", e.adapterID, e.args)
+	if err := rc.Reconfigure(context.Background(), resolved[0], resolved[1]); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	e.files = sourcePaths(meta, itemMeta)
+	r.mu.Unlock()
+	return nil
+}
+
+// changedNames returns the cleaned changed paths and the full and short keys
+// they are (or were) indexed under.
+func (r *Registry) changedNames(changed []string) (paths, names map[string]bool) {
+	paths = make(map[string]bool)
+	names = make(map[string]bool)
+	for _, p := range changed {
+		// Relative paths may be OS paths or paths on a virtual filesystem.
+		paths[p] = true
+		if abs, err := filepath.Abs(p); err == nil {
+			paths[abs] = true
+		}
+		names[strings.TrimSuffix(filepath.Base(p), ".json")] = true
+
+		for _, l := range r.searchMap.layers {
+			if rel, ok := l.keyFor(p); ok {
+				names[strings.TrimSuffix(rel, ".json")] = true
+			}
+		}
+	}
+	return paths, names
+}
+
+// affectedBy reports whether the entry was built from one of the changed
+// files, or may now resolve to a newly added one.
+func (e *adapterEntry) affectedBy(paths, names map[string]bool) bool {
+	for _, f := range e.files {
+		if paths[f] {
+			return true
+		}
+	}
+	if names[e.adapterID] {
+		return true
+	}
+	return len(e.args) > 0 && names[e.args[0]]
+}
+
+// Watch watches the SearchMap roots until ctx is done and calls Refresh for
+// every batch of changed config files. It uses native notifications (inotify)
+// on Linux when all roots are on the OS filesystem and polls through the
+// FileSystem interface otherwise.
+func (r *Registry) Watch(ctx context.Context, opts WatchOptions) error {
+	sm := r.searchMap
+	if sm == nil {
+		return fmt.Errorf("core: no SearchMap configured; call NewSearchMap first")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+
+	prev, err := snapshotFiles(sm)
+	if err != nil {
+		return err
+	}
+
+	var events <-chan struct{}
+	if sm.isLocal() && !opts.Poll {
+		n, err := newNotifier(sm.Roots())
+		if err != nil {
+			Log().Debugf("watch: falling back to polling: %v\n", err)
+		} else {
+			defer n.Close()
+			events = n.Events()
+		}
+	}
+
+	var tick <-chan time.Time
+	if events == nil {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick:
+		case <-events:
+			// Debounce bursts of events (editors write files in several steps).
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		cur, err := snapshotFiles(sm)
+		if err != nil {
+			Log().Errorf("watch: %v\n", err)
+			continue
+		}
+		changed := diffSnapshots(prev, cur)
+		prev = cur
+		if len(changed) == 0 {
+			continue
+		}
+
+		report, err := r.Refresh(changed...)
+		if opts.OnRefresh != nil {
+			opts.OnRefresh(report, err)
+		} else if err != nil {
+			Log().Errorf("watch: refresh: %v\n", err)
+		}
+	}
+}
+
+// fileStamp identifies a version of a config file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	sum     uint32 // content checksum, for files stamped without a stat
+}
+
+// snapshotFiles stamps every indexed config file under the SearchMap roots,
+// and their ignore files.
+func snapshotFiles(sm *SearchMap) (map[string]fileStamp, error) {
+	sm.mu.RLock()
+	opts := sm.opts
+	sm.mu.RUnlock()
+
+	out := make(map[string]fileStamp)
+	for _, l := range sm.layers {
+		_, err := l.walkConfigs(opts, func(cfgPath, rel string, d fs.DirEntry) error {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			out[cfgPath] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Editing the ignore file changes what is indexed.
+		if data, err := l.fs.ReadFile(l.join(IgnoreFile)); err == nil {
+			out[l.join(IgnoreFile)] = fileStamp{size: int64(len(data)), sum: crc32.ChecksumIEEE(data)}
+		}
+	}
+	return out, nil
+}
+
+// diffSnapshots returns the paths added, removed or changed between snapshots.
+func diffSnapshots(prev, cur map[string]fileStamp) []string {
+	var out []string
+	for p, s := range cur {
+		if old, ok := prev[p]; !ok || !old.modTime.Equal(s.modTime) || old.size != s.size || old.sum != s.sum {
+			out = append(out, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// notifier delivers a signal whenever something changes under the watched roots.
+type notifier interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// Refresh updates the default registry's adapters for the changed config files.
+func Refresh(changed ...string) (*RefreshReport, error) {
+	return defaultRegistry.Refresh(changed...)
+}
+
+// Watch watches the default registry's search roots until ctx is done.
+func Watch(ctx context.Context, opts WatchOptions) error {
+	return defaultRegistry.Watch(ctx, opts)
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package core
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF
+
+// inotifyNotifier watches every directory under the roots with inotify.
+type inotifyNotifier struct {
+	fd     int
+	file   *os.File
+	events chan struct{}
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor -> directory
+}
+
+func newNotifier(roots []string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	n := &inotifyNotifier{
+		fd: fd,
+		// A non-blocking fd is registered with the runtime poller, so Close
+		// unblocks a pending Read.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+		dirs:   make(map[int32]string),
+	}
+	for _, root := range roots {
+		if err := n.addTree(root); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotifyNotifier) Events() <-chan struct{} { return n.events }
+
+func (n *inotifyNotifier) Close() error { return n.file.Close() }
+
+// addTree adds a watch for dir and all directories below it.
+func (n *inotifyNotifier) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(n.fd, path, inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		n.mu.Lock()
+		n.dirs[int32(wd)] = path
+		n.mu.Unlock()
+		return nil
+	})
+}
+
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		nr, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= nr; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:start+nameLen]), "\x00")
+			off = start + nameLen
+
+			// Watch directories created (or moved in) after we started.
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				n.mu.Lock()
+				dir, ok := n.dirs[wd]
+				n.mu.Unlock()
+				if ok {
+					_ = n.addTree(filepath.Join(dir, name))
+				}
+			}
+		}
+
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
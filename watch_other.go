@@ -0,0 +1,9 @@
+//go:build !linux
+
+package core
+
+import "errors"
+
+func newNotifier(roots []string) (notifier, error) {
+	return nil, errors.New("native file notifications are not supported on this platform")
+}
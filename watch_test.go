@@ -0,0 +1,102 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/bartdeboer/go-core"
+)
+
+// WatchedAdp is rebuilt on config changes.
+type WatchedAdp struct {
+	Spec struct {
+		Value string `json:"value"`
+	}
+	Dep *LiveAdp `core:"live-adp"`
+}
+
+func (a *WatchedAdp) ConfigPtr() any { return &a.Spec }
+
+// LiveAdp is reconfigured in place on config changes.
+type LiveAdp struct {
+	Spec struct {
+		Value string `json:"value"`
+	}
+}
+
+func (a *LiveAdp) ConfigPtr() any { return &a.Spec }
+
+func (a *LiveAdp) Reconfigure(ctx context.Context, meta, itemMeta *core.MetaHeader) error {
+	return json.Unmarshal(meta.RawSpec, &a.Spec)
+}
+
+func TestRegistry_Refresh(t *testing.T) {
+	core.Register("watched-adp", func() core.Adapter { return &WatchedAdp{} })
+	core.Register("live-adp", func() core.Adapter { return &LiveAdp{} })
+
+	dir := t.TempDir()
+	live := filepath.Join(dir, "live-adp.json")
+	writeFile(t, filepath.Join(dir, "watched-adp.json"), `{"spec":{"value":"v1"}}`)
+	writeFile(t, live, `{"spec":{"value":"live-v1"}}`)
+	if _, err := core.SetDefaultSearchPath(dir); err != nil {
+		t.Fatalf("SearchMap: %v", err)
+	}
+
+	a1, err := core.NewAdapterAs[*WatchedAdp]("watched-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+
+	// Reconfigurable dependency: updated in place, parent untouched.
+	writeFile(t, live, `{"spec":{"value":"live-v2"}}`)
+	report, err := core.Refresh(live)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if len(report.Reconfigured) != 1 || len(report.Rebuilt) != 0 {
+		t.Fatalf("report = %+v, want one reconfigured adapter", report)
+	}
+	if a1.Dep.Spec.Value != "live-v2" {
+		t.Fatalf("Dep.Spec.Value = %q, want live-v2", a1.Dep.Spec.Value)
+	}
+
+	// New item config for the parent: rebuilt, dependency reused.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan *core.RefreshReport, 1)
+	go core.Watch(ctx, core.WatchOptions{
+		Interval: 20 * time.Millisecond,
+		Poll:     true,
+		OnRefresh: func(r *core.RefreshReport, err error) {
+			if err != nil {
+				t.Errorf("refresh: %v", err)
+			}
+			refreshed <- r
+		},
+	})
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(dir, "watched-adp.json"), `{"spec":{"value":"v2-longer"}}`)
+
+	select {
+	case report = <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for refresh")
+	}
+	if len(report.Rebuilt) != 1 {
+		t.Fatalf("report = %+v, want one rebuilt adapter", report)
+	}
+
+	a2, err := core.NewAdapterAs[*WatchedAdp]("watched-adp")
+	if err != nil {
+		t.Fatalf("NewAdapterAs: %v", err)
+	}
+	if a2 == a1 || a2.Spec.Value != "v2-longer" {
+		t.Fatalf("expected a rebuilt adapter with the new spec, got %+v", a2.Spec)
+	}
+	if a2.Dep != a1.Dep {
+		t.Fatalf("expected the reconfigured dependency to be reused")
+	}
+}